@@ -0,0 +1,264 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// matcher is the compiled matcher of the routes: a function
+// matching paths using nested switches on the path segments,
+// along with the routes it returns.
+type matcher struct {
+	r *routes
+
+	// Matcher function body
+	code bytes.Buffer
+	// Route and handler declarations
+	decls bytes.Buffer
+	// Route variable names
+	names map[*route]string
+	// The strings package is used
+	strings bool
+}
+
+// compile returns the compiled matcher of the routes. Paths are
+// matched as by router.Router: static segments are tried before
+// the parameter capture, backtracking if the deeper path fails
+// to match, and wildcards are tried last.
+func (r *routes) compile() *matcher {
+	m := &matcher{r: r, names: map[*route]string{}}
+	m.node(r.root)
+
+	return m
+}
+
+// WriteTo writes the matcher function and route declarations.
+func (m *matcher) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+
+	fn := *varName + "Match"
+	fmt.Fprintf(&b, "\n\n// %s matches the method and path against the compiled routes.\nfunc %s(m, u string, p router.Params) (*router.Route, router.HandlerFunc, router.Params) {\n", fn, fn)
+
+	if len(m.names) > 0 {
+		code := m.code.Bytes()
+
+		// Drop blank lines before closing braces
+		for bytes.Contains(code, []byte("\n\n}")) {
+			code = bytes.Replace(code, []byte("\n\n}"), []byte("\n}"), -1)
+		}
+
+		b.WriteString("var a *router.Route\n\n")
+		b.Write(code)
+		b.WriteString("\nreturn a, nil, nil\n}")
+		b.WriteString("\n\nvar (\n")
+		m.decls.WriteTo(&b)
+		b.WriteString(")")
+	} else {
+		b.WriteString("return nil, nil, nil\n}")
+	}
+
+	return b.WriteTo(w)
+}
+
+// routes returns the route variable names, ordered by pattern.
+func (m *matcher) routes() []string {
+	var l []*route
+
+	for c := range m.names {
+		l = append(l, c)
+	}
+
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].pattern < l[j].pattern
+	})
+
+	s := make([]string, len(l))
+
+	for i, c := range l {
+		s[i] = m.names[c]
+	}
+
+	return s
+}
+
+// node writes the code matching the path u against
+// the descendants of route c, capturing into p.
+func (m *matcher) node(c *route) {
+	var full, segs []string
+
+	for _, k := range c.children.keys() {
+		v := c.children[k]
+
+		if len(v.handlers) > 0 {
+			full = append(full, k)
+		}
+
+		if strings.IndexByte(k, '/') == -1 && v.descendants() {
+			segs = append(segs, k)
+		}
+	}
+
+	// Full static match (including optimized paths)
+	if len(full) > 0 {
+		m.code.WriteString("switch u {\n")
+
+		for _, k := range full {
+			fmt.Fprintf(&m.code, "case %q:\n", k)
+			m.leaf(c.children[k])
+		}
+
+		m.code.WriteString("}\n\n")
+	}
+
+	// The root path only matches the root route
+	if c == m.r.root && (c.child != nil || c.wildcard != nil) {
+		m.code.WriteString("if u == \"/\" {\nreturn a, nil, nil\n}\n\n")
+	}
+
+	var (
+		nested = c.child != nil && c.child.descendants()
+		leaf   = c.child != nil && len(c.child.handlers) > 0
+		cond   = "if "
+	)
+
+	if len(segs) > 0 || nested {
+		m.strings = true
+		m.code.WriteString("if i := strings.IndexByte(u, '/'); i != -1 {\ns, r := u[:i], u[i+1:]\n\n")
+
+		// Static
+		if len(segs) > 0 {
+			m.code.WriteString("switch s {\n")
+
+			for _, k := range segs {
+				fmt.Fprintf(&m.code, "case %q:\nu := r\n\n", k)
+				m.node(c.children[k])
+			}
+
+			m.code.WriteString("}\n\n")
+		}
+
+		// Capture parameter
+		if nested {
+			fmt.Fprintf(&m.code, "if %s {\np := p.Append(%q, s)\nu := r\n\n", m.check("s", c.child.check), c.child.param)
+			m.node(c.child)
+			m.code.WriteString("}\n")
+		}
+
+		m.code.WriteString("}")
+
+		if leaf {
+			m.code.WriteString(" else ")
+		} else {
+			m.code.WriteString("\n\n")
+		}
+	} else if leaf {
+		m.strings = true
+		cond += "strings.IndexByte(u, '/') == -1 && "
+	}
+
+	if leaf {
+		fmt.Fprintf(&m.code, "%s%s {\np := p.Append(%q, u)\n\n", cond, m.check("u", c.child.check), c.child.param)
+		m.leaf(c.child)
+		m.code.WriteString("}\n\n")
+	}
+
+	// Capture remaining path
+	if c.wildcard != nil {
+		fmt.Fprintf(&m.code, "if %s {\np := p.Append(%q, u)\n\n", m.check("u", c.wildcard.check), c.wildcard.param)
+		m.leaf(c.wildcard)
+		m.code.WriteString("}\n\n")
+	}
+}
+
+// leaf writes the code returning route c and its handler
+// for the method, declaring the route and its handlers.
+func (m *matcher) leaf(c *route) {
+	n := *varName + "Route" + strconv.Itoa(len(m.names))
+	m.names[c] = n
+
+	methods := keys(c.handlers)
+	fmt.Fprintf(&m.decls, "%s = &router.Route{\n", n)
+
+	if c.slash {
+		m.decls.WriteString("TrailingSlash: true,\n")
+	}
+
+	if c.name != "" {
+		fmt.Fprintf(&m.decls, "Name: %q,\n", c.name)
+	}
+
+	fmt.Fprintf(&m.decls, "Pattern: %q,\nHandlers: router.Handlers{\n", c.pattern)
+	m.code.WriteString("switch m {\n")
+
+	for _, k := range methods {
+		h := n + strings.Title(strings.ToLower(k))
+
+		fmt.Fprintf(&m.decls, "%q: %s,\n", k, h)
+		fmt.Fprintf(&m.code, "case %q:\nreturn %s, %s, p\n", k, n, h)
+	}
+
+	m.decls.WriteString("},\n}\n")
+
+	for _, k := range methods {
+		fmt.Fprintf(&m.decls, "%s%s = %s\n", n, strings.Title(strings.ToLower(k)), m.r.expr(c.handlers[k]))
+	}
+
+	fmt.Fprintf(&m.code, "}\n\na = a.Union(%s)\n", n)
+}
+
+// check returns the condition for capturing the variable v,
+// which must be non-empty and valid.
+func (m *matcher) check(v, check string) string {
+	if check == "" {
+		return v + ` != ""`
+	}
+
+	return v + ` != "" && ` + m.r.expr(check) + "(" + v + ")"
+}
+
+// depth returns the maximum number of parameters
+// captured by the descendants of route c.
+func (c *route) depth() int {
+	n := 0
+
+	for _, v := range c.children {
+		if d := v.depth(); d > n {
+			n = d
+		}
+	}
+
+	if c.child != nil {
+		if d := c.child.depth() + 1; d > n {
+			n = d
+		}
+	}
+
+	if c.wildcard != nil && n == 0 {
+		n = 1
+	}
+
+	return n
+}
+
+// descendants reports whether route c has descendant routes.
+func (c *route) descendants() bool {
+	return len(c.children) > 0 || c.child != nil || c.wildcard != nil
+}
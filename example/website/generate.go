@@ -13,6 +13,5 @@
 // limitations under the License.
 
 //go:generate routify -p website -v Routes
-//go:generate gofmt -w -s routes.go
 
 package website
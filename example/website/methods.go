@@ -16,7 +16,9 @@ package website
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/martingallagher/routify/router"
 )
@@ -33,6 +35,20 @@ func printnum(w http.ResponseWriter, r *http.Request, p router.Params) {
 	fmt.Fprintf(w, "printnum() = %s", p.Get("num"))
 }
 
+func logRequests(h router.HandlerFunc) router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p router.Params) {
+		start := time.Now()
+
+		h(w, r, p)
+		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
+	}
+}
+
+func notFound(w http.ResponseWriter, r *http.Request, err error) {
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, "Sorry, %s doesn't exist. Please try / instead.", r.URL.Path)
+}
+
 func validateNumber(s string) bool {
 	if s == "" {
 		return false
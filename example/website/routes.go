@@ -1,31 +1,44 @@
+// Code generated by routify; DO NOT EDIT.
+// Source: routes.yaml
+// SHA-256: f512d5bc20e4999d371172e7f02ce0591f6572db82749ae8fdf3e221dd571e79
+
 package website
 
 import "github.com/martingallagher/routify/router"
 
 var Routes = &router.Router{
-	Routes: router.Routes{
-		"GET": &router.Route{
-			Children: router.Routes{
-				"hello": &router.Route{
-					Child: &router.Route{
-						Param:       "str",
-						HandlerFunc: hello,
-					},
+	Root: &router.Route{
+		Children: router.Routes{
+			"/": &router.Route{
+				Pattern: "/",
+				Handlers: router.Handlers{
+					"GET": logRequests(index),
 				},
-				"printnum": &router.Route{
-					Child: &router.Route{
-						Param:       "num",
-						Check:       validateNumber,
-						HandlerFunc: printnum,
+			},
+			"hello": &router.Route{
+				Child: &router.Route{
+					Param:   "str",
+					Pattern: "/hello/:str",
+					Handlers: router.Handlers{
+						"GET": logRequests(hello),
 					},
 				},
-				"/": &router.Route{
-					HandlerFunc: index,
+			},
+			"printnum": &router.Route{
+				Child: &router.Route{
+					Param:   "num",
+					Check:   validateNumber,
+					Pattern: "/printnum/:num",
+					Handlers: router.Handlers{
+						"GET": logRequests(printnum),
+					},
 				},
 			},
 		},
 	},
 	Validators: router.Validators{
-		"$num": validateNumber,
+		"num": validateNumber,
 	},
+	NotFound:     notFound,
+	PanicHandler: router.DefaultPanicHandler,
 }
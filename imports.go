@@ -0,0 +1,118 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const routerImport = `"github.com/martingallagher/routify/router"`
+
+// Package qualified references, e.g. example.com/app/users.Show
+var refPattern = regexp.MustCompile(`[^\s(),:]*/[^\s(),:]*`)
+
+// ref registers the packages of the qualified references
+// in the routes.yaml handler, validator or middleware.
+func (r *routes) ref(s string) error {
+	for _, v := range refPattern.FindAllString(s, -1) {
+		i := strings.LastIndexByte(v, '.')
+
+		if i < strings.LastIndexByte(v, '/') || i == len(v)-1 {
+			return fmt.Errorf("%s: invalid package reference", v)
+		}
+
+		r.imports[v[:i]] = ""
+	}
+
+	return nil
+}
+
+// expr returns the Go expression of the routes.yaml handler,
+// validator or middleware, qualifying references by package name.
+func (r *routes) expr(s string) string {
+	if len(r.imports) == 0 {
+		return s
+	}
+
+	return refPattern.ReplaceAllStringFunc(s, func(v string) string {
+		i := strings.LastIndexByte(v, '.')
+
+		return r.imports[v[:i]] + v[i:]
+	})
+}
+
+// importSpecs names the referenced packages, returning their
+// sorted import specs, standard library packages first. Packages
+// are named after their import path, aliased if the name differs
+// from the final path element or is taken, e.g. by another package
+// of the same name.
+func (r *routes) importSpecs() (std, pkg []string) {
+	// Generated identifiers
+	used := map[string]bool{"router": true, *varName: true}
+
+	// Compiled matcher package and variables
+	if *mode == "compiled" {
+		for _, v := range []string{"strings", "a", "i", "m", "p", "r", "s", "u"} {
+			used[v] = true
+		}
+	}
+
+	for _, p := range keys(r.imports) {
+		c := importName(p)
+		n := c
+
+		for i := 2; used[n]; i++ {
+			n = c + strconv.Itoa(i)
+		}
+
+		used[n] = true
+		r.imports[p] = n
+		v := strconv.Quote(p)
+
+		if n != path.Base(p) {
+			v = n + " " + v
+		}
+
+		// Standard library paths have no domain
+		if strings.IndexByte(strings.SplitN(p, "/", 2)[0], '.') == -1 {
+			std = append(std, v)
+		} else {
+			pkg = append(pkg, v)
+		}
+	}
+
+	return std, pkg
+}
+
+// importName returns the conventional package name of the import
+// path: the final path element, skipping major version elements
+// (example.com/app/v2) and suffixes (gopkg.in/yaml.v2).
+func importName(p string) string {
+	n := path.Base(p)
+
+	if d := path.Dir(p); d != "." && len(n) > 1 && n[0] == 'v' && strings.Trim(n[1:], "0123456789") == "" {
+		n = path.Base(d)
+	}
+
+	if i := strings.IndexByte(n, '.'); i > 0 {
+		n = n[:i]
+	}
+
+	return identifier(n)
+}
@@ -0,0 +1,455 @@
+// Code generated by routify; DO NOT EDIT.
+// Source: routes.yaml
+// SHA-256: a613f38411a68bc5d5cb7ed70807705afcd08ab1b735e652df5e1f09a10ec92b
+
+package router
+
+import "strings"
+
+var compiledRoutes = &Router{
+	Matcher:   compiledRoutesMatch,
+	MaxParams: 21,
+	Compiled: []*Route{
+		compiledRoutesRoute0,
+		compiledRoutesRoute3,
+		compiledRoutesRoute4,
+		compiledRoutesRoute6,
+		compiledRoutesRoute5,
+		compiledRoutesRoute1,
+		compiledRoutesRoute2,
+		compiledRoutesRoute8,
+		compiledRoutesRoute7,
+	},
+	Validators: Validators{
+		"day":   IsDay,
+		"month": IsMonth,
+		"year":  IsYear,
+	},
+	Names: Names{
+		"schema": "/schemas/:schema",
+	},
+}
+
+// compiledRoutesSchemaURL returns the URL of the schema route.
+func compiledRoutesSchemaURL(schema string) (string, error) {
+	return compiledRoutes.URL("schema", schema)
+}
+
+// compiledRoutesMatch matches the method and path against the compiled routes.
+func compiledRoutesMatch(m, u string, p Params) (*Route, HandlerFunc, Params) {
+	var a *Route
+
+	switch u {
+	case "/":
+		switch m {
+		case "GET":
+			return compiledRoutesRoute0, compiledRoutesRoute0Get, p
+		}
+
+		a = a.Union(compiledRoutesRoute0)
+	case "static/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q/r/s/t/u":
+		switch m {
+		case "GET":
+			return compiledRoutesRoute1, compiledRoutesRoute1Get, p
+		}
+
+		a = a.Union(compiledRoutesRoute1)
+	case "testing/hello/world":
+		switch m {
+		case "GET":
+			return compiledRoutesRoute2, compiledRoutesRoute2Get, p
+		}
+
+		a = a.Union(compiledRoutesRoute2)
+	}
+
+	if i := strings.IndexByte(u, '/'); i != -1 {
+		s, r := u[:i], u[i+1:]
+
+		switch s {
+		case "files":
+			u := r
+
+			if u != "" {
+				p := p.Append("filepath", u)
+
+				switch m {
+				case "GET":
+					return compiledRoutesRoute3, compiledRoutesRoute3Get, p
+				}
+
+				a = a.Union(compiledRoutesRoute3)
+			}
+
+		case "nofunc":
+			u := r
+
+			if i := strings.IndexByte(u, '/'); i != -1 {
+				s, r := u[:i], u[i+1:]
+
+				if s != "" {
+					p := p.Append("a", s)
+					u := r
+
+					if i := strings.IndexByte(u, '/'); i != -1 {
+						s, r := u[:i], u[i+1:]
+
+						if s != "" {
+							p := p.Append("b", s)
+							u := r
+
+							if i := strings.IndexByte(u, '/'); i != -1 {
+								s, r := u[:i], u[i+1:]
+
+								if s != "" {
+									p := p.Append("c", s)
+									u := r
+
+									if i := strings.IndexByte(u, '/'); i != -1 {
+										s, r := u[:i], u[i+1:]
+
+										if s != "" {
+											p := p.Append("d", s)
+											u := r
+
+											if i := strings.IndexByte(u, '/'); i != -1 {
+												s, r := u[:i], u[i+1:]
+
+												if s != "" {
+													p := p.Append("e", s)
+													u := r
+
+													if i := strings.IndexByte(u, '/'); i != -1 {
+														s, r := u[:i], u[i+1:]
+
+														if s != "" {
+															p := p.Append("f", s)
+															u := r
+
+															if i := strings.IndexByte(u, '/'); i != -1 {
+																s, r := u[:i], u[i+1:]
+
+																if s != "" {
+																	p := p.Append("g", s)
+																	u := r
+
+																	if i := strings.IndexByte(u, '/'); i != -1 {
+																		s, r := u[:i], u[i+1:]
+
+																		if s != "" {
+																			p := p.Append("h", s)
+																			u := r
+
+																			if i := strings.IndexByte(u, '/'); i != -1 {
+																				s, r := u[:i], u[i+1:]
+
+																				if s != "" {
+																					p := p.Append("i", s)
+																					u := r
+
+																					if i := strings.IndexByte(u, '/'); i != -1 {
+																						s, r := u[:i], u[i+1:]
+
+																						if s != "" {
+																							p := p.Append("j", s)
+																							u := r
+
+																							if i := strings.IndexByte(u, '/'); i != -1 {
+																								s, r := u[:i], u[i+1:]
+
+																								if s != "" {
+																									p := p.Append("k", s)
+																									u := r
+
+																									if i := strings.IndexByte(u, '/'); i != -1 {
+																										s, r := u[:i], u[i+1:]
+
+																										if s != "" {
+																											p := p.Append("l", s)
+																											u := r
+
+																											if i := strings.IndexByte(u, '/'); i != -1 {
+																												s, r := u[:i], u[i+1:]
+
+																												if s != "" {
+																													p := p.Append("m", s)
+																													u := r
+
+																													if i := strings.IndexByte(u, '/'); i != -1 {
+																														s, r := u[:i], u[i+1:]
+
+																														if s != "" {
+																															p := p.Append("n", s)
+																															u := r
+
+																															if i := strings.IndexByte(u, '/'); i != -1 {
+																																s, r := u[:i], u[i+1:]
+
+																																if s != "" {
+																																	p := p.Append("o", s)
+																																	u := r
+
+																																	if i := strings.IndexByte(u, '/'); i != -1 {
+																																		s, r := u[:i], u[i+1:]
+
+																																		if s != "" {
+																																			p := p.Append("p", s)
+																																			u := r
+
+																																			if i := strings.IndexByte(u, '/'); i != -1 {
+																																				s, r := u[:i], u[i+1:]
+
+																																				if s != "" {
+																																					p := p.Append("q", s)
+																																					u := r
+
+																																					if i := strings.IndexByte(u, '/'); i != -1 {
+																																						s, r := u[:i], u[i+1:]
+
+																																						if s != "" {
+																																							p := p.Append("r", s)
+																																							u := r
+
+																																							if i := strings.IndexByte(u, '/'); i != -1 {
+																																								s, r := u[:i], u[i+1:]
+
+																																								if s != "" {
+																																									p := p.Append("s", s)
+																																									u := r
+
+																																									if i := strings.IndexByte(u, '/'); i != -1 {
+																																										s, r := u[:i], u[i+1:]
+
+																																										if s != "" {
+																																											p := p.Append("t", s)
+																																											u := r
+
+																																											if strings.IndexByte(u, '/') == -1 && u != "" {
+																																												p := p.Append("u", u)
+
+																																												switch m {
+																																												case "GET":
+																																													return compiledRoutesRoute4, compiledRoutesRoute4Get, p
+																																												}
+
+																																												a = a.Union(compiledRoutesRoute4)
+																																											}
+																																										}
+																																									}
+																																								}
+																																							}
+																																						}
+																																					}
+																																				}
+																																			}
+																																		}
+																																	}
+																																}
+																															}
+																														}
+																													}
+																												}
+																											}
+																										}
+																									}
+																								}
+																							}
+																						}
+																					}
+																				}
+																			}
+																		}
+																	}
+																}
+															}
+														}
+													}
+												}
+											}
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+
+		case "schemas":
+			u := r
+
+			if i := strings.IndexByte(u, '/'); i != -1 {
+				s, r := u[:i], u[i+1:]
+
+				if s != "" {
+					p := p.Append("schema", s)
+					u := r
+
+					if i := strings.IndexByte(u, '/'); i != -1 {
+						s, r := u[:i], u[i+1:]
+
+						switch s {
+						case "archives":
+							u := r
+
+							if i := strings.IndexByte(u, '/'); i != -1 {
+								s, r := u[:i], u[i+1:]
+
+								if s != "" && IsYear(s) {
+									p := p.Append("year", s)
+									u := r
+
+									if i := strings.IndexByte(u, '/'); i != -1 {
+										s, r := u[:i], u[i+1:]
+
+										if s != "" && IsMonth(s) {
+											p := p.Append("month", s)
+											u := r
+
+											if strings.IndexByte(u, '/') == -1 && u != "" && IsDay(u) {
+												p := p.Append("day", u)
+
+												switch m {
+												case "GET":
+													return compiledRoutesRoute5, compiledRoutesRoute5Get, p
+												}
+
+												a = a.Union(compiledRoutesRoute5)
+											}
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			} else if u != "" {
+				p := p.Append("schema", u)
+
+				switch m {
+				case "DELETE":
+					return compiledRoutesRoute6, compiledRoutesRoute6Delete, p
+				case "GET":
+					return compiledRoutesRoute6, compiledRoutesRoute6Get, p
+				case "PATCH":
+					return compiledRoutesRoute6, compiledRoutesRoute6Patch, p
+				case "POST":
+					return compiledRoutesRoute6, compiledRoutesRoute6Post, p
+				case "PUT":
+					return compiledRoutesRoute6, compiledRoutesRoute6Put, p
+				}
+
+				a = a.Union(compiledRoutesRoute6)
+			}
+
+		case "users":
+			u := r
+
+			if i := strings.IndexByte(u, '/'); i != -1 {
+				s, r := u[:i], u[i+1:]
+
+				switch s {
+				case "me":
+					u := r
+
+					if strings.IndexByte(u, '/') == -1 && u != "" {
+						p := p.Append("tab", u)
+
+						switch m {
+						case "GET":
+							return compiledRoutesRoute7, compiledRoutesRoute7Get, p
+						}
+
+						a = a.Union(compiledRoutesRoute7)
+					}
+				}
+			} else if u != "" {
+				p := p.Append("user", u)
+
+				switch m {
+				case "GET":
+					return compiledRoutesRoute8, compiledRoutesRoute8Get, p
+				}
+
+				a = a.Union(compiledRoutesRoute8)
+			}
+		}
+	}
+
+	return a, nil, nil
+}
+
+var (
+	compiledRoutesRoute0 = &Route{
+		Pattern: "/",
+		Handlers: Handlers{
+			"GET": compiledRoutesRoute0Get,
+		},
+	}
+	compiledRoutesRoute0Get = exampleHandler
+	compiledRoutesRoute1    = &Route{
+		Pattern: "/static/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q/r/s/t/u",
+		Handlers: Handlers{
+			"GET": compiledRoutesRoute1Get,
+		},
+	}
+	compiledRoutesRoute1Get = exampleHandler
+	compiledRoutesRoute2    = &Route{
+		Pattern: "/testing/hello/world",
+		Handlers: Handlers{
+			"GET": compiledRoutesRoute2Get,
+		},
+	}
+	compiledRoutesRoute2Get = exampleHandler
+	compiledRoutesRoute3    = &Route{
+		Pattern: "/files/*filepath",
+		Handlers: Handlers{
+			"GET": compiledRoutesRoute3Get,
+		},
+	}
+	compiledRoutesRoute3Get = exampleHandler
+	compiledRoutesRoute4    = &Route{
+		Pattern: "/nofunc/:a/:b/:c/:d/:e/:f/:g/:h/:i/:j/:k/:l/:m/:n/:o/:p/:q/:r/:s/:t/:u",
+		Handlers: Handlers{
+			"GET": compiledRoutesRoute4Get,
+		},
+	}
+	compiledRoutesRoute4Get = exampleHandler
+	compiledRoutesRoute5    = &Route{
+		Pattern: "/schemas/:schema/archives/:year/:month/:day",
+		Handlers: Handlers{
+			"GET": compiledRoutesRoute5Get,
+		},
+	}
+	compiledRoutesRoute5Get = exampleHandler
+	compiledRoutesRoute6    = &Route{
+		Name:    "schema",
+		Pattern: "/schemas/:schema",
+		Handlers: Handlers{
+			"DELETE": compiledRoutesRoute6Delete,
+			"GET":    compiledRoutesRoute6Get,
+			"PATCH":  compiledRoutesRoute6Patch,
+			"POST":   compiledRoutesRoute6Post,
+			"PUT":    compiledRoutesRoute6Put,
+		},
+	}
+	compiledRoutesRoute6Delete = exampleHandler
+	compiledRoutesRoute6Get    = exampleHandler
+	compiledRoutesRoute6Patch  = exampleHandler
+	compiledRoutesRoute6Post   = exampleHandler
+	compiledRoutesRoute6Put    = exampleHandler
+	compiledRoutesRoute7       = &Route{
+		Pattern: "/users/me/:tab",
+		Handlers: Handlers{
+			"GET": compiledRoutesRoute7Get,
+		},
+	}
+	compiledRoutesRoute7Get = exampleHandler
+	compiledRoutesRoute8    = &Route{
+		Pattern: "/users/:user",
+		Handlers: Handlers{
+			"GET": compiledRoutesRoute8Get,
+		},
+	}
+	compiledRoutesRoute8Get = exampleHandler
+)
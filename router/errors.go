@@ -23,6 +23,8 @@ var (
 	ErrRouteNotFound = NewError(http.StatusNotFound, "route not found")
 	// ErrBadRequest represents HTTP 400.
 	ErrBadRequest = NewError(http.StatusBadRequest, "bad request")
+	// ErrRedirect represents a redirect to the canonical route path.
+	ErrRedirect = NewError(http.StatusMovedPermanently, "redirect to canonical path")
 )
 
 // Error represents a routing error.
@@ -47,3 +49,45 @@ func (e *Error) Error() string {
 func NewError(c int, s string) *Error {
 	return &Error{c, s}
 }
+
+// ConflictError represents a route conflicting with an existing route.
+type ConflictError struct {
+	// ErrParamConflict, ErrDuplicateRoute or ErrShadowedRoute
+	Err error
+	// Method of the conflicting route, if known
+	Method string
+	// Pattern of the conflicting route
+	Pattern string
+	// Pattern of the existing route
+	Existing string
+}
+
+// Error returns the error string.
+func (e *ConflictError) Error() string {
+	s := e.Pattern
+
+	if e.Method != "" {
+		s = e.Method + " " + s
+	}
+
+	return e.Err.Error() + ": " + s + " conflicts with " + e.Existing
+}
+
+// Unwrap returns the underlying conflict error.
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// ValidatorError represents a parameter or
+// wildcard route without a validator.
+type ValidatorError struct {
+	// Parameter name
+	Param string
+	// Pattern of a route capturing the parameter
+	Pattern string
+}
+
+// Error returns the error string.
+func (e *ValidatorError) Error() string {
+	return "missing validator: " + e.Param + " captured by " + e.Pattern
+}
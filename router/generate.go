@@ -12,12 +12,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:generate routify -i routes.yaml -p router -v routes
-// Strip import for in-package testing
-//go:generate sed -i "s/^.*import.*//g" routes.go
-// Strip package identifiers
-//go:generate sed -i "s/router\\.//g" routes.go
-// Format
-//go:generate gofmt -w -s routes.go
+// Generate routes.go within the router package for testing
+//go:generate routify -local -i routes.yaml -p router -v routes
+
+// Generate compiled_routes.go, matching the same routes in compiled mode
+//go:generate routify -local -mode=compiled -i routes.yaml -o compiled_routes.go -p router -v compiledRoutes
 
 package router
@@ -0,0 +1,303 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Group registers routes under a common path prefix,
+// sharing the routes tree and validators of its router.
+type Group struct {
+	router     *Router
+	prefix     string
+	middleware []Middleware
+}
+
+// Group returns a group for registering routes
+// under the given path prefix.
+func (r *Router) Group(prefix string) *Group {
+	return &Group{router: r, prefix: cleanPrefix(prefix)}
+}
+
+// Group returns a sub-group for registering routes under the
+// given path prefix, relative to the group. The sub-group
+// inherits the group middleware.
+func (g *Group) Group(prefix string) *Group {
+	return &Group{
+		router:     g.router,
+		prefix:     g.prefix + cleanPrefix(prefix),
+		middleware: g.middleware[:len(g.middleware):len(g.middleware)],
+	}
+}
+
+// Add adds a route for the given method and path, relative to
+// the group prefix. The handler is wrapped by the given middleware,
+// the group middleware and the router middleware, in that order.
+func (g *Group) Add(m, u string, h HandlerFunc, mw ...Middleware) error {
+	return g.router.Add(m, g.path(u), h, g.chain(mw)...)
+}
+
+// AddNamed adds a named route for the given method and path,
+// relative to the group prefix. The handler is wrapped by the
+// given middleware, the group middleware and the router
+// middleware, in that order.
+func (g *Group) AddNamed(n, m, u string, h HandlerFunc, mw ...Middleware) error {
+	return g.router.AddNamed(n, m, g.path(u), h, g.chain(mw)...)
+}
+
+// Remove removes the route for the given method
+// and path, relative to the group prefix.
+func (g *Group) Remove(m, u string) error {
+	return g.router.Remove(m, g.path(u))
+}
+
+// Replace replaces the handler of the route for the given method
+// and path, relative to the group prefix. The handler is wrapped
+// by the given middleware, the group middleware and the router
+// middleware, in that order.
+func (g *Group) Replace(m, u string, h HandlerFunc, mw ...Middleware) error {
+	return g.router.Replace(m, g.path(u), h, g.chain(mw)...)
+}
+
+// chain returns the group middleware followed by the given middleware.
+func (g *Group) chain(mw []Middleware) []Middleware {
+	return append(g.middleware[:len(g.middleware):len(g.middleware)], mw...)
+}
+
+// AddValidator adds a validating function to
+// the validators map of the router.
+func (g *Group) AddValidator(n string, f func(string) bool) {
+	g.router.AddValidator(n, f)
+}
+
+// path returns the given path prefixed with the group prefix.
+// The path "/" refers to the group prefix itself, or the
+// root path of root-scoped groups.
+func (g *Group) path(u string) string {
+	if u != "/" {
+		return joinPath(g.prefix, u)
+	} else if g.prefix == "" {
+		return u
+	}
+
+	return g.prefix
+}
+
+// Mount grafts the routes of the given router under the path
+// prefix. Routes are copied, so routes subsequently added to
+// either router are not shared. Named routes and validators
+// are added to the router, keeping existing validators.
+// Mounted handlers are wrapped by the router middleware.
+// A ConflictError is returned if a mounted route conflicts
+// with an existing route, including routes capturing the same
+// parameter using a different validator. Routers using
+// a Matcher can't be mounted.
+func (r *Router) Mount(prefix string, s *Router) error {
+	prefix = cleanPrefix(prefix)
+
+	if strings.IndexByte(prefix, '*') != -1 {
+		return ErrInvalidPath
+	} else if s.Matcher != nil {
+		return ErrCompiledRoutes
+	}
+
+	m := s.load()
+
+	return r.update(func(t *tree) error {
+		for k, v := range m.names {
+			if v = joinPath(prefix, v); t.names[k] != "" && t.names[k] != v {
+				return ErrDuplicateName
+			}
+		}
+
+		var c *Route
+
+		if prefix == "" {
+			t.root = t.root.clone()
+			c = t.root
+		} else {
+			var err error
+
+			if c, err = t.node("", prefix, false); err != nil {
+				return err
+			}
+		}
+
+		if root := m.root; root != nil {
+			// Root path of the mounted router
+			if v, exists := root.Children["/"]; exists && prefix != "" {
+				if err := merge(c, v, r.middleware, prefix); err != nil {
+					return err
+				}
+
+				root = root.clone()
+				delete(root.Children, "/")
+			}
+
+			if err := merge(c, root, r.middleware, prefix); err != nil {
+				return err
+			}
+
+			if err := m.root.walk(prefix, func(u string, v *Route) error {
+				return t.shadowed(u, v)
+			}); err != nil {
+				return err
+			}
+		}
+
+		for k, v := range m.validators {
+			if _, exists := t.validators[k]; !exists {
+				t.validators = t.validators.with(k, v)
+			}
+		}
+
+		for k, v := range m.names {
+			t.names = t.names.with(k, joinPath(prefix, v))
+		}
+
+		return nil
+	})
+}
+
+// shadowed returns a ConflictError if a route for the given path
+// and one of the methods of route s shadows another route.
+func (t *tree) shadowed(u string, s *Route) error {
+	p, err := segments(u)
+
+	if err != nil {
+		return err
+	}
+
+	for m := range s.Handlers {
+		var l []*Route
+
+		t.root.lookup(p, func(c *Route) {
+			if _, exists := c.Handlers[m]; exists {
+				l = append(l, c)
+			}
+		})
+
+		if len(l) > 1 {
+			return &ConflictError{ErrShadowedRoute, m, u, pattern(p, l[0].TrailingSlash)}
+		}
+	}
+
+	return nil
+}
+
+// merge copies the handlers and descendants of route s into
+// route c, wrapping the handlers by the given middleware.
+// Route c must be a copy; descendants are copied as required.
+// The prefix is the pattern of route c.
+func merge(c, s *Route, mw []Middleware, prefix string) error {
+	// Keep existing validators
+	if c.Check == nil {
+		c.Check = s.Check
+	} else if s.Check != nil && reflect.ValueOf(c.Check).Pointer() != reflect.ValueOf(s.Check).Pointer() {
+		return &ConflictError{ErrValidatorConflict, "", s.pattern(prefix), c.pattern(prefix)}
+	}
+
+	if len(s.Handlers) > 0 {
+		if len(c.Handlers) > 0 && c.TrailingSlash != s.TrailingSlash {
+			return &ConflictError{ErrSlashConflict, "", s.pattern(prefix), c.pattern(prefix)}
+		}
+
+		for k, v := range s.Handlers {
+			if _, exists := c.Handlers[k]; exists {
+				return &ConflictError{ErrDuplicateRoute, k, s.pattern(prefix), c.pattern(prefix)}
+			}
+
+			c.Handlers[k] = wrap(v, mw)
+		}
+
+		c.TrailingSlash = s.TrailingSlash
+		c.Name = s.Name
+		c.Pattern = s.pattern(prefix)
+	}
+
+	for k, v := range s.Children {
+		u := prefix
+
+		// Root path
+		if k != "/" {
+			u += "/" + k
+		}
+
+		d := c.Children[k].clone()
+
+		if err := merge(d, v, mw, u); err != nil {
+			return err
+		}
+
+		c.Children[k] = d
+	}
+
+	var err error
+
+	if c.Child, err = mergeParam(c.Child, s.Child, mw, prefix+"/:"); err != nil {
+		return err
+	}
+
+	c.Wildcard, err = mergeParam(c.Wildcard, s.Wildcard, mw, prefix+"/*")
+
+	return err
+}
+
+// mergeParam merges the parameter or wildcard route s into
+// route c, returning the resulting route. The prefix is the
+// pattern of the parent route followed by the parameter marker.
+func mergeParam(c, s *Route, mw []Middleware, prefix string) (*Route, error) {
+	if s == nil {
+		return c, nil
+	} else if c == nil {
+		c = &Route{Param: s.Param}
+	} else if c.Param != s.Param {
+		return nil, &ConflictError{ErrParamConflict, "", s.pattern(prefix + s.Param), c.pattern(prefix + c.Param)}
+	}
+
+	c = c.clone()
+
+	return c, merge(c, s, mw, prefix+s.Param)
+}
+
+// joinPath joins the path prefix and path, preserving the
+// trailing slash of the path.
+func joinPath(prefix, u string) string {
+	s := strings.Trim(u, "/")
+
+	if s == "" {
+		return prefix
+	}
+
+	s = prefix + "/" + s
+
+	if hasTrailingSlash(u) {
+		s += "/"
+	}
+
+	return s
+}
+
+// cleanPrefix returns the path prefix with a leading slash and
+// without a trailing slash; the root prefix is an empty string.
+func cleanPrefix(prefix string) string {
+	if prefix = strings.Trim(prefix, "/"); prefix == "" {
+		return ""
+	}
+
+	return "/" + prefix
+}
@@ -0,0 +1,171 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	r := &Router{}
+	g := r.Group("/api/v1/")
+	g.AddValidator("id", func(s string) bool {
+		return s != "0"
+	})
+
+	if err := g.Add("GET", "/", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = g.Add("GET", "users/:id", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = g.Group("users").AddNamed("posts", "GET", "/:id/posts/", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Group("").Add("GET", "/", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Group("/").Add("POST", "/", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url string
+		err error
+	}{
+		{"/api/v1", nil},
+		{"/api/v1/users/1", nil},
+		{"/api/v1/users/0", ErrRouteNotFound},
+		{"/api/v1/users/1/posts/", nil},
+		{"/users/1", ErrRouteNotFound},
+		{"/", nil},
+	}
+
+	for _, c := range tests {
+		req, err := http.NewRequest("GET", c.url, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		} else if _, _, err = r.Get(req); err != c.err {
+			t.Fatalf("%s: unexpected error %v", c.url, err)
+		}
+	}
+
+	if u, err := r.URL("posts", "1"); err != nil {
+		t.Fatal(err)
+	} else if u != "/api/v1/users/1/posts/" {
+		t.Fatalf("unexpected URL %q", u)
+	}
+}
+
+func TestMount(t *testing.T) {
+	r := &Router{}
+	s := &Router{}
+
+	if err := r.Add("GET", "/v1/status", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = s.Add("GET", "/", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = s.Add("POST", "/users/:id", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Mount("/v1", s); err != nil {
+		t.Fatal(err)
+	} else if err = r.Mount("/v2", routes); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, url string
+		err         error
+	}{
+		{"GET", "/v1", nil},
+		{"GET", "/v1/status", nil},
+		{"POST", "/v1/users/1", nil},
+		{"GET", "/v1/users/1", ErrInvalidMethod},
+		{"GET", "/v2", nil},
+		{"GET", "/v2/schemas/test/archives/2015/02/12", nil},
+		{"GET", "/v2/static/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q/r/s/t/u", nil},
+		{"GET", "/schemas/test", ErrRouteNotFound},
+	}
+
+	for _, c := range tests {
+		req, err := http.NewRequest(c.method, c.url, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		} else if _, _, err = r.Get(req); err != c.err {
+			t.Fatalf("%s %s: unexpected error %v", c.method, c.url, err)
+		}
+	}
+
+	if u, err := r.URL("schema", "test"); err != nil {
+		t.Fatal(err)
+	} else if u != "/v2/schemas/test" {
+		t.Fatalf("unexpected URL %q", u)
+	}
+
+	// Mounted routes are copied
+	if err := s.Add("GET", "/users/:id", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "/v1/users/1", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if _, _, err = r.Get(req); err != ErrInvalidMethod {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestMountValidators(t *testing.T) {
+	r := &Router{}
+	r.AddValidator("id", func(s string) bool { return s == "a" })
+
+	s := &Router{}
+	s.AddValidator("id", func(s string) bool { return s == "b" })
+
+	if err := r.Add("GET", "/api/:id", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = s.Add("GET", "/:id/x", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Mount("/api", s); err == nil {
+		t.Fatal("expected conflict error")
+	} else if e, ok := err.(*ConflictError); !ok || *e != (ConflictError{ErrValidatorConflict, "", "/api/:id/x", "/api/:id"}) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Unvalidated mounted routes use the existing validator
+	s = &Router{}
+
+	if err := s.Add("GET", "/:id/x", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Mount("/api", s); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		url string
+		err error
+	}{
+		{"/api/a", nil},
+		{"/api/a/x", nil},
+		{"/api/b", ErrRouteNotFound},
+		{"/api/b/x", ErrRouteNotFound},
+	} {
+		if _, _, _, err := r.Match("GET", c.url); err != c.err {
+			t.Fatalf("%s: unexpected error %v", c.url, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+// Middleware wraps a handler function, returning the wrapped function.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Use appends middleware to the router middleware chain. Chains
+// are composed when routes are added, so the middleware only
+// applies to routes added afterwards. The first middleware
+// is the outermost.
+func (r *Router) Use(mw ...Middleware) {
+	r.mu.Lock()
+	r.middleware = append(r.middleware, mw...)
+	r.mu.Unlock()
+}
+
+// Use appends middleware to the group middleware chain,
+// applying to routes added to the group afterwards.
+func (g *Group) Use(mw ...Middleware) {
+	g.middleware = append(g.middleware, mw...)
+}
+
+// wrap returns the handler wrapped by the given
+// middleware, the first middleware being the outermost.
+func wrap(h HandlerFunc, mw []Middleware) HandlerFunc {
+	if h == nil {
+		return nil
+	}
+
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+
+	return h
+}
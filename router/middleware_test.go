@@ -0,0 +1,83 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+
+	mw := func(s string) Middleware {
+		return func(h HandlerFunc) HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request, p Params) {
+				calls = append(calls, s)
+				h(w, r, p)
+			}
+		}
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request, p Params) {
+		calls = append(calls, "handler")
+	}
+
+	r := &Router{}
+
+	if err := r.Add("GET", "/before", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Use(mw("router1"), mw("router2"))
+
+	g := r.Group("/api")
+	g.Use(mw("group"))
+
+	if err := r.Add("GET", "/after", handler, mw("route")); err != nil {
+		t.Fatal(err)
+	} else if err = g.Add("GET", "/users", handler, mw("route")); err != nil {
+		t.Fatal(err)
+	} else if err = g.Group("/v1").AddNamed("v1", "GET", "/users", handler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Mount("/mounted", routes); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct{ url, calls string }{
+		{"/before", "handler"},
+		{"/after", "router1 router2 route handler"},
+		{"/api/users", "router1 router2 group route handler"},
+		{"/api/v1/users", "router1 router2 group handler"},
+		{"/mounted", "router1 router2"},
+	}
+
+	for _, c := range tests {
+		req, err := http.NewRequest("GET", c.url, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		calls = nil
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		if v := strings.Join(calls, " "); v != c.calls {
+			t.Fatalf("%s: unexpected calls %q", c.url, v)
+		}
+	}
+}
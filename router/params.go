@@ -29,7 +29,14 @@ var (
 
 type param struct{ k, v string }
 
-// Params contains the parsed URL parameters.
+// patternKey is the parameter key of the matched route pattern;
+// parameter names can't contain slashes.
+const patternKey = "/"
+
+// Params contains the parsed URL parameters. Parameters passed
+// to handlers by the router are reused once the handler returns:
+// handlers must copy the values they retain, e.g. for goroutines,
+// rather than the Params.
 type Params []param
 
 // Get returns the parameter value for the given key.
@@ -43,6 +50,22 @@ func (p Params) Get(k string) string {
 	return ""
 }
 
+// Append returns the parameters with the given
+// parameter appended, e.g. for compiled matchers.
+func (p Params) Append(k, v string) Params {
+	return append(p, param{k, v})
+}
+
+// Pattern returns the pattern of the matched route, e.g.
+// /blog/:year/:month, for requests served by the router.
+func (p Params) Pattern() string {
+	if l := len(p); l > 0 && p[l-1].k == patternKey {
+		return p[l-1].v
+	}
+
+	return ""
+}
+
 // GetInt attempts to get the given key as int64.
 func (p Params) GetInt(k string) (int64, error) {
 	v := p.Get(k)
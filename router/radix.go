@@ -0,0 +1,265 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import "strings"
+
+// node is a route compiled for matching, indexing the static
+// child routes by a radix tree of their keys.
+type node struct {
+	route    *Route
+	methods  []string
+	handlers []HandlerFunc
+	static   *edge
+	child    *node
+	wildcard *node
+
+	// Maximum parameters captured by descendants
+	depth int
+}
+
+// edge is a radix tree edge labelled by a byte prefix of the
+// static route keys. Child edges are indexed by their first byte.
+type edge struct {
+	prefix  string
+	indices string
+	edges   []*edge
+	node    *node // Route of the key ending at the edge
+	slash   bool  // Prefix contains a slash
+}
+
+// compile returns the compiled route r, reusing the compiled routes
+// of the previously published index. Published routes are never
+// modified, so a route compiled previously is compiled along with
+// its descendants.
+func compile(r *Route, prev *node) *node {
+	if r == nil {
+		return nil
+	} else if prev != nil && prev.route == r {
+		return prev
+	}
+
+	n := &node{route: r}
+
+	for _, m := range r.Allow() {
+		n.methods = append(n.methods, m)
+		n.handlers = append(n.handlers, r.Handlers[m])
+	}
+
+	var child, wildcard *node
+
+	if prev != nil {
+		child, wildcard = prev.child, prev.wildcard
+	}
+
+	if len(r.Children) > 0 {
+		n.static = &edge{}
+
+		for _, k := range r.keys() {
+			var c *node
+
+			if prev != nil && prev.static != nil {
+				c = prev.static.get(k)
+			}
+
+			c = compile(r.Children[k], c)
+			n.static.insert(k).node = c
+
+			if c.depth > n.depth {
+				n.depth = c.depth
+			}
+		}
+	}
+
+	if n.child = compile(r.Child, child); n.child != nil && n.child.depth >= n.depth {
+		n.depth = n.child.depth + 1
+	}
+
+	if n.wildcard = compile(r.Wildcard, wildcard); n.wildcard != nil && n.depth == 0 {
+		n.depth = 1
+	}
+
+	return n
+}
+
+// match attempts to match the given method and path against the
+// route's descendants, returning the matching route and the
+// captured parameters. Static routes are tried before the
+// parameter route, backtracking if the deeper path fails to
+// match, and the wildcard route is only used as a last resort.
+// The union of the routes matching the path but not the method
+// is stored in a.
+func (n *node) match(m, u string, p Params, a **Route) (*Route, HandlerFunc, Params) {
+	var full, seg *node
+
+	if n.static != nil {
+		full, seg = n.static.lookup(u)
+	}
+
+	// Full static match (including optimized paths)
+	if full != nil {
+		if h := full.handler(m, a); h != nil {
+			return full.route, h, p
+		}
+	}
+
+	s, r := u, ""
+	i := strings.IndexByte(u, '/')
+
+	if i != -1 {
+		s, r = u[:i], u[i+1:]
+
+		// Static
+		if seg != nil {
+			if v, h, c := seg.match(m, r, p, a); v != nil {
+				return v, h, c
+			}
+		}
+	}
+
+	// Capture parameter
+	if c := n.child; c != nil && s != "" && (c.route.Check == nil || c.route.Check(s)) {
+		p := append(p, param{c.route.Param, s})
+
+		if i == -1 {
+			if h := c.handler(m, a); h != nil {
+				return c.route, h, p
+			}
+		} else if v, h, p := c.match(m, r, p, a); v != nil {
+			return v, h, p
+		}
+	}
+
+	// Capture remaining path
+	c := n.wildcard
+
+	if c == nil || u == "" || c.route.Check != nil && !c.route.Check(u) {
+		return nil, nil, nil
+	}
+
+	h := c.handler(m, a)
+
+	if h == nil {
+		return nil, nil, nil
+	}
+
+	return c.route, h, append(p, param{c.route.Param, u})
+}
+
+// handler returns the handler for the given method. Routes with
+// handlers for other methods are added to the union in a.
+func (n *node) handler(m string, a **Route) HandlerFunc {
+	for i, v := range n.methods {
+		if v == m {
+			return n.handlers[i]
+		}
+	}
+
+	if len(n.methods) > 0 {
+		*a = (*a).Union(n.route)
+	}
+
+	return nil
+}
+
+// lookup returns the routes of the static keys equal to the path
+// and to the first path segment, walking the radix tree once.
+func (e *edge) lookup(u string) (full, seg *node) {
+	slash := false
+
+	for n := 0; e != nil; e = e.next(u[n]) {
+		if !strings.HasPrefix(u[n:], e.prefix) {
+			break
+		} else if n += len(e.prefix); n == len(u) {
+			return e.node, seg
+		}
+
+		// First path segment
+		if slash = slash || e.slash; !slash && u[n] == '/' {
+			seg = e.node
+			slash = true
+		}
+	}
+
+	return nil, seg
+}
+
+// get returns the route of the static key.
+func (e *edge) get(k string) *node {
+	for e != nil {
+		if !strings.HasPrefix(k, e.prefix) {
+			return nil
+		} else if k = k[len(e.prefix):]; k == "" {
+			return e.node
+		}
+
+		e = e.next(k[0])
+	}
+
+	return nil
+}
+
+// next returns the child edge starting with the given byte.
+func (e *edge) next(c byte) *edge {
+	for i := 0; i < len(e.indices); i++ {
+		if e.indices[i] == c {
+			return e.edges[i]
+		}
+	}
+
+	return nil
+}
+
+// insert adds the static key to the radix tree,
+// returning the edge the key ends at.
+func (e *edge) insert(k string) *edge {
+	for {
+		// Longest common prefix
+		i := 0
+
+		for i < len(k) && i < len(e.prefix) && k[i] == e.prefix[i] {
+			i++
+		}
+
+		// Split the edge
+		if i < len(e.prefix) {
+			c := &edge{e.prefix[i:], e.indices, e.edges, e.node, e.slash}
+
+			e.prefix = e.prefix[:i]
+			e.indices = c.prefix[:1]
+			e.edges = []*edge{c}
+			e.node = nil
+			e.slash = strings.IndexByte(e.prefix, '/') != -1
+			c.slash = strings.IndexByte(c.prefix, '/') != -1
+		}
+
+		if k = k[i:]; k == "" {
+			return e
+		}
+
+		c := e.next(k[0])
+
+		if c == nil {
+			c = &edge{prefix: k, slash: strings.IndexByte(k, '/') != -1}
+
+			e.indices += k[:1]
+			e.edges = append(e.edges, c)
+
+			return c
+		}
+
+		e = c
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRadix(t *testing.T) {
+	keys := []string{"/", "files", "file", "fig", "static/a/b", "static", "st", "users"}
+	e := &edge{}
+	n := map[string]*node{}
+
+	for _, k := range keys {
+		n[k] = &node{}
+		e.insert(k).node = n[k]
+	}
+
+	for _, k := range keys {
+		if v := e.get(k); v != n[k] {
+			t.Fatalf("%s: unexpected node", k)
+		}
+	}
+
+	for _, k := range []string{"", "f", "fil", "filesx", "static/a", "u"} {
+		if v := e.get(k); v != nil {
+			t.Fatalf("%s: unexpected node", k)
+		}
+	}
+
+	tests := []struct {
+		path      string
+		full, seg *node
+	}{
+		{"files", n["files"], nil},
+		{"file/a", nil, n["file"]},
+		{"static/a/b", n["static/a/b"], n["static"]},
+		{"static/a/c", nil, n["static"]},
+		{"st/a/b", nil, n["st"]},
+		{"stat/a", nil, nil},
+		{"/", n["/"], nil},
+		{"x", nil, nil},
+	}
+
+	for _, c := range tests {
+		if full, seg := e.lookup(c.path); full != c.full || seg != c.seg {
+			t.Fatalf("%s: unexpected nodes", c.path)
+		}
+	}
+}
+
+func TestRadixCompile(t *testing.T) {
+	r := &Router{}
+
+	if err := r.Mount("/", routes); err != nil {
+		t.Fatal(err)
+	}
+
+	prev := r.load().index
+
+	if err := r.Add("GET", "/users/:user/posts", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged routes are reused
+	index := r.load().index
+
+	if index == prev || index.static.get("users") == prev.static.get("users") {
+		t.Fatal("expected changed routes to be compiled")
+	} else if index.static.get("files") != prev.static.get("files") {
+		t.Fatal("expected unchanged routes to be reused")
+	}
+
+	req, err := http.NewRequest("GET", "/users/1/posts", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if _, _, err = r.Get(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkRadixLongStatic(b *testing.B) {
+	benchmarkMatch(b, longStatic)
+}
+
+func BenchmarkRadixShortParam(b *testing.B) {
+	benchmarkMatch(b, shortParam)
+}
+
+func BenchmarkRadixLongParam(b *testing.B) {
+	benchmarkMatch(b, longParam)
+}
+
+func benchmarkMatch(b *testing.B, u string) {
+	r := &Router{}
+
+	if err := r.Mount("/", routes); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.Match("GET", u)
+	}
+}
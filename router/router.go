@@ -16,8 +16,14 @@ package router
 
 import (
 	"errors"
+	"log"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 var (
@@ -25,6 +31,32 @@ var (
 	ErrInvalidRoute = errors.New("invalid route")
 	// ErrInvalidPath - URL parse error; invalid route path.
 	ErrInvalidPath = errors.New("invalid route path")
+	// ErrDuplicateName - route name already used by another route path.
+	ErrDuplicateName = errors.New("duplicate route name")
+	// ErrUnknownRoute - no route exists for the given name.
+	ErrUnknownRoute = errors.New("unknown route name")
+	// ErrParamCount - number of parameters doesn't match the route.
+	ErrParamCount = errors.New("invalid number of route parameters")
+	// ErrInvalidParam - parameter value is empty or failed validation.
+	ErrInvalidParam = errors.New("invalid route parameter")
+	// ErrNotRegistered - no route exists for the given method and path.
+	ErrNotRegistered = errors.New("route not registered")
+	// ErrParamConflict - parameter name differs from the existing route.
+	ErrParamConflict = errors.New("conflicting route parameter")
+	// ErrDuplicateRoute - route exists for the given method and path.
+	ErrDuplicateRoute = errors.New("duplicate route")
+	// ErrShadowedRoute - route exists for the given method and an
+	// equivalent path, e.g. as separate and optimized static routes.
+	ErrShadowedRoute = errors.New("shadowed route")
+	// ErrValidatorConflict - mounted route parameter validated
+	// by a different validator than the existing routes.
+	ErrValidatorConflict = errors.New("conflicting parameter validator")
+	// ErrSlashConflict - trailing slash differs from the existing
+	// route for other methods on the same path.
+	ErrSlashConflict = errors.New("conflicting trailing slash")
+	// ErrCompiledRoutes - route matched by the Matcher routes,
+	// which can't be removed, replaced or mounted.
+	ErrCompiledRoutes = errors.New("compiled routes can't be changed")
 )
 
 // HandlerFunc defines the interface for
@@ -32,170 +64,613 @@ var (
 // except that it includes the parsed URL parameters.
 type HandlerFunc func(http.ResponseWriter, *http.Request, Params)
 
+// ErrorHandlerFunc defines the interface for functions
+// handling routing errors, typically *Error values.
+type ErrorHandlerFunc func(http.ResponseWriter, *http.Request, error)
+
+// PanicHandlerFunc defines the interface for functions handling
+// panics recovered from handlers, receiving the matched parameters
+// and the recovered value.
+type PanicHandlerFunc func(http.ResponseWriter, *http.Request, Params, interface{})
+
+// SlashPolicy defines how trailing slashes are matched.
+type SlashPolicy int
+
+const (
+	// SlashLenient matches paths with or without trailing slashes.
+	SlashLenient SlashPolicy = iota
+	// SlashStrict only matches paths with trailing slashes
+	// if the route was registered with a trailing slash.
+	SlashStrict
+	// SlashRedirect redirects requests to the path with or without
+	// the trailing slash, as the route was registered.
+	SlashRedirect
+)
+
 // Router represents the defined routes and parameter validators.
+//
+// Root, Validators and Names hold the initial routes, such as the
+// routes generated by routify. Routes may be added, e.g. using Add,
+// while serving requests: changes are made to a copy of the routes
+// which is then published atomically, without locking requests.
+// The initial routes fields aren't updated and must not be
+// modified once the router is in use.
+//
+// Requests are matched using a radix tree compiled from the
+// routes when they're published; the initial routes are
+// compiled when the router is first used.
 type Router struct {
-	Routes     Routes
+	Root       *Route
 	Validators map[string]func(string) bool
+	Names      Names
+
+	// Matcher matches the initial routes in place of Root,
+	// e.g. as generated by routify in compiled mode. Routes
+	// added to the router are matched if Matcher doesn't
+	// match a route for the method.
+	Matcher Matcher
+	// MaxParams is the maximum number of parameters captured
+	// by the Matcher routes. Parameters of the other routes
+	// are counted when the routes are compiled.
+	MaxParams int
+	// Compiled lists the Matcher routes for Walk, Routes
+	// and Verify.
+	Compiled []*Route
+
+	// NotFound handles requests not matching any route.
+	NotFound ErrorHandlerFunc
+	// MethodNotAllowed handles requests matching a route
+	// for other methods only. The Allow header is set
+	// before the handler is called.
+	MethodNotAllowed ErrorHandlerFunc
+	// ErrorHandler handles all other routing errors and
+	// errors without a more specific handler. If nil,
+	// only the status code of the error is written.
+	ErrorHandler ErrorHandlerFunc
+	// PanicHandler handles panics recovered from handlers.
+	// If nil, panics are not recovered.
+	PanicHandler PanicHandlerFunc
+
+	// SlashPolicy defines how trailing slashes are matched.
+	SlashPolicy SlashPolicy
+	// RedirectFixedPath redirects requests to the cleaned path,
+	// resolving "." and ".." elements and duplicate slashes.
+	RedirectFixedPath bool
+
+	// DisableAutoOptions disables automatic replies to OPTIONS
+	// requests for paths without an OPTIONS handler.
+	DisableAutoOptions bool
+	// DisableAutoHead disables serving HEAD requests with the
+	// GET handler for paths without a HEAD handler.
+	DisableAutoHead bool
+
+	mu         sync.Mutex   // Serializes route changes
+	tree       atomic.Value // Published routes (*tree)
+	params     sync.Pool    // Parameters of served requests (*Params)
+	middleware []Middleware
 }
 
+// Matcher is a compiled routes matcher. The path is given without
+// leading and trailing slashes, or as "/" for the root path. The
+// matching route is returned along with its handler for the method
+// and the captured parameters, appended to p. If the path only
+// matches routes for other methods, the union of the matching
+// routes (see Route.Union) is returned without a handler.
+type Matcher func(m, u string, p Params) (*Route, HandlerFunc, Params)
+
 // Routes holds static route mappings.
 type Routes map[string]*Route
 
+// Handlers holds route handler functions keyed by HTTP method.
+type Handlers map[string]HandlerFunc
+
+// Names holds route paths keyed by route name.
+type Names map[string]string
+
 // Validators holds parameter validating functions.
 type Validators map[string]func(string) bool
 
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Method  string
+	Pattern string
+	// Captured parameter names, which are also the validator names
+	Params []string
+}
+
 // Route represents an individual route/end-point.
 type Route struct {
-	Param       string            // Parameter name
-	Check       func(string) bool // Function to check if section is valid
-	HandlerFunc HandlerFunc       // Handler function use to serve
-	Child       *Route            // Child route (parameter capture)
-	Children    Routes            // Child map (static paths)
+	Param         string            // Parameter name
+	Check         func(string) bool // Function to check if section is valid
+	Handlers      Handlers          // Handler functions keyed by HTTP method
+	Child         *Route            // Child route (parameter capture)
+	Children      Routes            // Child map (static paths)
+	Wildcard      *Route            // Wildcard route (captures remaining path)
+	TrailingSlash bool              // Route path has a trailing slash
+	Name          string            // Route name
+	Pattern       string            // Route pattern, e.g. /blog/:year
+}
+
+// MatchInfo describes a route matched by Match.
+type MatchInfo struct {
+	Pattern string // Route pattern, e.g. /blog/:year
+	Name    string // Route name
+
+	allow []string
+}
+
+// Allow returns the sorted methods allowed for the matched path,
+// as listed by the Allow header: HEAD and OPTIONS requests are
+// also answered unless the automatically handled methods are
+// disabled.
+func (i *MatchInfo) Allow() []string {
+	return i.allow
 }
 
 // Get attempts to get a route for the given request.
+// Static routes take precedence over parameter captures;
+// when a static branch fails to match the remainder of the
+// path, the parameter branch is tried instead.
 func (r *Router) Get(req *http.Request) (HandlerFunc, Params, error) {
-	u := req.URL.Path
+	h, p, _, err := r.Match(req.Method, req.URL.Path)
 
+	return h, p, err
+}
+
+// Match attempts to match a route for the given method and path,
+// returning the handler, the captured parameters and a description
+// of the matched route. If the path only matches routes for other
+// methods, the description is returned along with ErrInvalidMethod.
+func (r *Router) Match(m, u string) (HandlerFunc, Params, *MatchInfo, error) {
+	h, p, route, err := r.get(m, u, nil)
+
+	if route == nil {
+		return h, p, nil, err
+	}
+
+	a := route
+
+	// The empty method matches no route, collecting
+	// the handlers of all routes matching the path
+	if err == nil {
+		_, _, _, a = r.find("", u, nil)
+	}
+
+	return h, p, &MatchInfo{route.Pattern, route.Name, r.methods(a)}, err
+}
+
+// get attempts to get a route for the given method and path.
+// If the path only matches routes for other methods the
+// matching route is returned along with ErrInvalidMethod.
+// If the trailing slash doesn't match the route and the
+// slash policy is SlashRedirect, the matching route is
+// returned along with ErrRedirect. Parameters are appended to p.
+func (r *Router) get(m, u string, p Params) (HandlerFunc, Params, *Route, error) {
 	if u == "" {
-		return nil, nil, ErrBadRequest
+		return nil, nil, nil, ErrBadRequest
 	}
 
-	route, exists := r.Routes[req.Method]
+	route, h, c, a := r.find(m, u, p)
 
-	if !exists {
-		return nil, nil, ErrInvalidMethod
-	} else if u == "/" {
-		if route, exists = route.Children["/"]; exists && route.HandlerFunc != nil {
-			return route.HandlerFunc, nil, nil
+	var err error
+
+	if route == nil {
+		if a == nil {
+			return nil, nil, nil, ErrRouteNotFound
 		}
 
-		return nil, nil, ErrRouteNotFound
+		route, err = a, ErrInvalidMethod
 	}
 
-	u = stripSlashes(u)
-
-	// Exit early for full static match
-	if v, exists := route.Children[u]; exists {
-		if v.HandlerFunc == nil {
-			return nil, nil, ErrRouteNotFound
+	if r.SlashPolicy != SlashLenient && route.TrailingSlash != hasTrailingSlash(u) {
+		if r.SlashPolicy == SlashStrict {
+			return nil, nil, nil, ErrRouteNotFound
 		}
 
-		return v.HandlerFunc, nil, nil
+		return nil, nil, route, ErrRedirect
+	} else if err != nil {
+		return nil, nil, route, err
 	}
 
-	var p Params
+	return h, c, route, nil
+}
+
+// find matches the method, in any case, and path, returning the
+// matching route, its handler and the captured parameters, appended
+// to p. If no route matches the method, the union of the routes
+// matching the path is returned in a.
+func (r *Router) find(m, u string, p Params) (route *Route, h HandlerFunc, c Params, a *Route) {
+	// Routes are added with upper case methods
+	m = strings.ToUpper(m)
+	s := u
+
+	if u != "/" {
+		s = stripSlashes(u)
+	}
+
+	if r.Matcher != nil {
+		if route, h, c = r.Matcher(m, s, p); h == nil {
+			route, a, c = nil, route, p
+		}
+	}
 
-	for {
-		// Early exit for optimized paths
-		if v, exists := route.Children[u]; exists {
-			if v.HandlerFunc != nil {
-				return v.HandlerFunc, p, nil
+	if root := r.load().index; route == nil && root != nil {
+		if u == "/" {
+			if root.static != nil {
+				if v := root.static.get("/"); v != nil {
+					if h = v.handler(m, &a); h != nil {
+						route = v.route
+					}
+				}
 			}
+		} else {
+			route, h, c = root.match(m, s, p, &a)
+		}
+	}
+
+	return route, h, c, a
+}
+
+// Union returns route r with the handlers of route c added, e.g.
+// to list the methods allowed for a path matched by both routes.
+// Neither route is modified: a copy of r is returned if c adds
+// methods, or c if r is nil.
+func (r *Route) Union(c *Route) *Route {
+	if r == nil || r == c {
+		return c
+	}
 
-			route = v
+	u := r
 
+	for m, h := range c.Handlers {
+		if _, exists := r.Handlers[m]; exists {
 			continue
+		} else if u == r {
+			v := *r
+			v.Handlers = make(Handlers, len(r.Handlers)+len(c.Handlers))
+
+			for k, h := range r.Handlers {
+				v.Handlers[k] = h
+			}
+
+			u = &v
+		}
+
+		u.Handlers[m] = h
+	}
+
+	return u
+}
+
+// Allow returns the sorted list of methods the route handles.
+func (r *Route) Allow() []string {
+	m := make([]string, 0, len(r.Handlers))
+
+	for k := range r.Handlers {
+		m = append(m, k)
+	}
+
+	sort.Strings(m)
+
+	return m
+}
+
+// Add adds a route for the given method and path to the routes tree.
+// The handler is wrapped by the given middleware, which is in turn
+// wrapped by the router middleware.
+func (r *Router) Add(m, u string, h HandlerFunc, mw ...Middleware) error {
+	if err := r.shadowed(m, u); err != nil {
+		return err
+	}
+
+	return r.update(func(t *tree) error {
+		_, err := t.add(m, u, wrap(wrap(h, mw), r.middleware))
+
+		return err
+	})
+}
+
+// AddNamed adds a named route for the given method and path
+// to the routes tree. Named routes can be built using URL.
+// The handler is wrapped by the given middleware, which is in
+// turn wrapped by the router middleware.
+func (r *Router) AddNamed(n, m, u string, h HandlerFunc, mw ...Middleware) error {
+	if n == "" || u == "" {
+		return ErrInvalidRoute
+	} else if u[0] != '/' {
+		u = "/" + u
+	}
+
+	if err := r.shadowed(m, u); err != nil {
+		return err
+	}
+
+	return r.update(func(t *tree) error {
+		if v, exists := t.names[n]; exists && v != u {
+			return ErrDuplicateName
 		}
 
-		s := u
-		i := strings.IndexByte(u, '/')
+		c, err := t.add(m, u, wrap(wrap(h, mw), r.middleware))
 
-		if i != -1 {
-			s = u[:i]
-			u = u[i+1:]
+		if err != nil {
+			return err
 		}
 
-		if route.Child != nil {
-			// Capture parameter
-			route = route.Child
+		c.Name = n
+		t.names = t.names.with(n, u)
 
-			if route.Check != nil && !route.Check(s) {
-				return nil, nil, ErrRouteNotFound
-			}
+		return nil
+	})
+}
+
+// Walk calls f for each registered route, ordered by pattern and
+// method, including the Compiled routes. Patterns use the ":"
+// parameter and "*" wildcard prefixes; params are the captured
+// parameter names, in order, which are also the names of their
+// validators. Walking stops if f returns an error, which is
+// returned.
+func (r *Router) Walk(f func(m, u string, params []string, h HandlerFunc) error) error {
+	type entry struct {
+		u string
+		c *Route
+	}
+
+	var l []entry
+
+	if root := r.load().root; root != nil {
+		root.walk("", func(u string, c *Route) error {
+			l = append(l, entry{u, c})
+
+			return nil
+		})
+	}
 
-			p = append(p, param{route.Param, s})
-		} else if route, exists = route.Children[s]; !exists {
-			// Static
-			return nil, nil, ErrRouteNotFound
+	if len(r.Compiled) > 0 {
+		for _, c := range r.Compiled {
+			l = append(l, entry{c.Pattern, c})
 		}
 
-		if i == -1 {
-			break
+		sort.SliceStable(l, func(i, j int) bool {
+			return patternLess(l[i].u, l[j].u)
+		})
+	}
+
+	for _, v := range l {
+		params := patternParams(v.u)
+
+		for _, m := range v.c.Allow() {
+			if err := f(m, v.u, params, v.c.Handlers[m]); err != nil {
+				return err
+			}
 		}
 	}
 
-	if route == nil || route.HandlerFunc == nil {
-		return nil, nil, ErrRouteNotFound
+	return nil
+}
+
+// patternLess reports whether pattern a is walked before pattern b:
+// segment by segment, static segments before parameters, parameters
+// before wildcards and routes before their descendants.
+func patternLess(a, b string) bool {
+	p, q := strings.Split(stripSlashes(a), "/"), strings.Split(stripSlashes(b), "/")
+
+	for i := 0; i < len(p) && i < len(q); i++ {
+		if p[i] == q[i] {
+			continue
+		} else if x, y := segmentRank(p[i]), segmentRank(q[i]); x != y {
+			return x < y
+		}
+
+		return p[i] < q[i]
 	}
 
-	return route.HandlerFunc, p, nil
+	return len(p) < len(q)
 }
 
-// Add adds a route for the given method to the routes map.
-func (r *Router) Add(m, u string, h HandlerFunc) error {
-	if m == "" || u == "" || h == nil {
-		return ErrInvalidRoute
+// segmentRank returns the walk order of the pattern segment.
+func segmentRank(s string) int {
+	switch {
+	case s != "" && s[0] == ':':
+		return 1
+	case s != "" && s[0] == '*':
+		return 2
 	}
 
-	m = strings.ToUpper(m)
+	return 0
+}
 
-	var c *Route
+// patternParams returns the parameter and wildcard
+// names captured by the route pattern, in order.
+func patternParams(u string) []string {
+	var params []string
 
-	if r.Routes == nil {
-		r.Routes = Routes{m: &Route{}}
-	} else if _, exists := r.Routes[m]; !exists {
-		r.Routes[m] = &Route{}
+	for _, v := range strings.Split(u, "/") {
+		if v != "" && (v[0] == ':' || v[0] == '*') {
+			params = append(params, v[1:])
+		}
 	}
 
-	c = r.Routes[m]
+	return params
+}
+
+// Routes returns the registered routes, ordered by pattern and method.
+func (r *Router) Routes() []RouteInfo {
+	var l []RouteInfo
 
-	var p []string
+	r.Walk(func(m, u string, params []string, _ HandlerFunc) error {
+		l = append(l, RouteInfo{m, u, params})
 
-	if u != "/" {
-		p = strings.Split(stripSlashes(u), "/")
-	} else {
-		p = []string{"/"}
+		return nil
+	})
+
+	return l
+}
+
+// URL returns the URL path of the named route, filling the route
+// parameters in order with the given values. Values are URL escaped
+// and checked using the parameter validators.
+func (r *Router) URL(n string, params ...string) (string, error) {
+	t := r.load()
+	u, exists := t.names[n]
+
+	if !exists {
+		return "", ErrUnknownRoute
+	} else if u == "/" {
+		if len(params) > 0 {
+			return "", ErrParamCount
+		}
+
+		return u, nil
 	}
 
-	for i, l := 0, len(p); i < l; i++ {
-		if p[i] == "" {
-			return ErrInvalidPath
+	var (
+		b []byte
+		i int
+	)
+
+	for _, v := range strings.Split(stripSlashes(u), "/") {
+		b = append(b, '/')
+
+		if v == "" || v[0] != ':' && v[0] != '$' && v[0] != '*' {
+			b = append(b, v...)
+
+			continue
+		} else if i == len(params) {
+			return "", ErrParamCount
 		}
 
-		// Parameter
-		if p[i][0] == ':' || p[i][0] == '$' {
-			if c.Child == nil || c.Child.Param != p[i][1:] {
-				c.Child = &Route{
-					Param: p[i][1:],
-					Check: r.Validators[p[i][1:]],
+		p := params[i]
+		i++
+
+		if !validValue(p, v[0] == '*') {
+			return "", ErrInvalidParam
+		} else if f := t.validators[v[1:]]; f != nil && !f(p) {
+			return "", ErrInvalidParam
+		}
+
+		// Wildcard values may span multiple segments
+		if v[0] == '*' {
+			for j, s := range strings.Split(p, "/") {
+				if j > 0 {
+					b = append(b, '/')
 				}
-			}
 
-			c = c.Child
+				b = append(b, url.PathEscape(s)...)
+			}
 
 			continue
 		}
 
-		v, n := staticPath(p[i:])
-		i += n
+		b = append(b, url.PathEscape(p)...)
+	}
+
+	if i != len(params) {
+		return "", ErrParamCount
+	} else if hasTrailingSlash(u) {
+		b = append(b, '/')
+	}
+
+	return string(b), nil
+}
+
+// validValue reports whether the parameter value has no empty,
+// "." or ".." path segments, which would build a URL matching
+// another route. Parameter values are a single segment.
+func validValue(p string, wildcard bool) bool {
+	l := []string{p}
+
+	if wildcard {
+		l = strings.Split(p, "/")
+	}
 
-		if c.Children == nil {
-			c.Children = Routes{v: &Route{}}
-		} else if _, exists := c.Children[v]; !exists {
-			c.Children[v] = &Route{}
+	for _, s := range l {
+		if s == "" || s == "." || s == ".." {
+			return false
 		}
+	}
+
+	return true
+}
 
-		c = c.Children[v]
+// Remove removes the route for the given method and path. The path
+// must match the path the route was added with, e.g. using the same
+// parameter names. Routes left without handlers are pruned.
+func (r *Router) Remove(m, u string) error {
+	if m == "" || u == "" {
+		return ErrInvalidRoute
 	}
 
-	c.HandlerFunc = h
+	err := r.update(func(t *tree) error {
+		return t.remove(strings.ToUpper(m), u)
+	})
+
+	return r.compiled(m, u, err)
+}
+
+// Replace replaces the handler of the route for the given method
+// and path. The handler is wrapped by the given middleware, which
+// is in turn wrapped by the router middleware.
+func (r *Router) Replace(m, u string, h HandlerFunc, mw ...Middleware) error {
+	if m == "" || u == "" {
+		return ErrInvalidRoute
+	}
+
+	err := r.update(func(t *tree) error {
+		return t.replace(strings.ToUpper(m), u, wrap(wrap(h, mw), r.middleware))
+	})
+
+	return r.compiled(m, u, err)
+}
+
+// shadowed returns a ConflictError if the Matcher routes handle
+// the method for the path u, matched literally, e.g. using the
+// parameter names as values. The Matcher is tried first, so
+// the route would never be served.
+func (r *Router) shadowed(m, u string) error {
+	c := r.match(m, u)
+
+	if c == nil {
+		return nil
+	}
+
+	u = "/" + stripSlashes(u)
+
+	if u == c.Pattern {
+		return &ConflictError{ErrDuplicateRoute, strings.ToUpper(m), u, c.Pattern}
+	}
+
+	return &ConflictError{ErrShadowedRoute, strings.ToUpper(m), u, c.Pattern}
+}
+
+// compiled returns ErrCompiledRoutes in place of ErrNotRegistered
+// if the route for the method and path is a Matcher route.
+func (r *Router) compiled(m, u string, err error) error {
+	if err == ErrNotRegistered && r.match(m, u) != nil {
+		return ErrCompiledRoutes
+	}
+
+	return err
+}
+
+// match returns the Matcher route handling the method
+// for the path u, matched literally.
+func (r *Router) match(m, u string) *Route {
+	if r.Matcher == nil || m == "" || u == "" {
+		return nil
+	} else if u != "/" {
+		u = stripSlashes(u)
+	}
+
+	if c, h, _ := r.Matcher(strings.ToUpper(m), u, nil); h != nil {
+		return c
+	}
 
 	return nil
 }
 
-// AddValidator adds a validating function to
-// the validators map.
+// AddValidator adds a validating function to the validators
+// map. Existing parameter and wildcard routes with the given
+// name are validated using the function.
 func (r *Router) AddValidator(n string, f func(string) bool) {
 	if n == "" {
 		return
@@ -203,28 +678,214 @@ func (r *Router) AddValidator(n string, f func(string) bool) {
 		n = n[1:]
 	}
 
-	if r.Validators == nil {
-		r.Validators = Validators{n: f}
-	} else {
-		r.Validators[n] = f
+	r.update(func(t *tree) error {
+		t.validators = t.validators.with(n, f)
+		t.root = t.root.relink(n, f)
+
+		return nil
+	})
+}
+
+// Verify returns a ValidatorError for the first parameter or
+// wildcard route without a validator, including parameters of the
+// Compiled routes without a Validators entry. Strict routers should
+// call Verify at startup, once validators have been added.
+func (r *Router) Verify() error {
+	if root := r.load().root; root != nil {
+		if err := root.verify(""); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range r.Compiled {
+		for _, v := range patternParams(c.Pattern) {
+			if r.Validators[v] == nil {
+				return &ValidatorError{v, c.Pattern}
+			}
+		}
 	}
+
+	return nil
 }
 
-// ServeHTTP implements the Handler interface.
+// ServeHTTP implements the Handler interface. The parameters
+// passed to handlers are pooled and reused once the handler
+// returns, so handlers must not retain them.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	h, p, err := r.Get(req)
+	u := req.URL.Path
+
+	if r.RedirectFixedPath && u != "" {
+		if c := cleanPath(u); c != u {
+			// Apply the slash policy to the cleaned path
+			if _, _, _, err := r.get(req.Method, c, nil); err == ErrRedirect {
+				redirect(w, req, toggleSlash(c))
+
+				return
+			} else if err != ErrRouteNotFound {
+				redirect(w, req, c)
+
+				return
+			}
+		}
+	}
+
+	c := r.acquire()
+	defer r.params.Put(c)
+
+	h, p, route, err := r.get(req.Method, u, *c)
+
+	if err == ErrRedirect {
+		redirect(w, req, toggleSlash(cleanPath(u)))
+
+		return
+	} else if err == ErrInvalidMethod {
+		if req.Method == "OPTIONS" && !r.DisableAutoOptions {
+			w.Header().Set("Allow", r.allow(route))
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		} else if req.Method == "HEAD" && !r.DisableAutoHead {
+			if h, p, route, err := r.get("GET", req.URL.Path, *c); err == nil {
+				r.serve(h, headResponseWriter{w}, req, append(p, param{patternKey, route.Pattern}))
+
+				return
+			}
+		}
+
+		w.Header().Set("Allow", r.allow(route))
+	}
 
 	if err != nil {
+		r.error(w, req, err)
+
+		return
+	}
+
+	r.serve(h, w, req, append(p, param{patternKey, route.Pattern}))
+}
+
+// serve calls the handler, passing panics to the
+// panic handler if one is set.
+func (r *Router) serve(h HandlerFunc, w http.ResponseWriter, req *http.Request, p Params) {
+	if r.PanicHandler != nil {
+		defer r.recover(w, req, p)
+	}
+
+	h(w, req, p)
+}
+
+// acquire returns pooled parameters with capacity for the
+// parameters captured by the routes and the route pattern.
+func (r *Router) acquire() *Params {
+	n := r.MaxParams
+
+	if root := r.load().index; root != nil && root.depth > n {
+		n = root.depth
+	}
+
+	if p, _ := r.params.Get().(*Params); p != nil && cap(*p) > n {
+		*p = (*p)[:0]
+
+		return p
+	}
+
+	p := make(Params, 0, n+1)
+
+	return &p
+}
+
+// recover passes panics recovered from handlers to the panic
+// handler. Handlers aborted with http.ErrAbortHandler are
+// re-panicked, so the server aborts the response.
+func (r *Router) recover(w http.ResponseWriter, req *http.Request, p Params) {
+	if v := recover(); v == http.ErrAbortHandler {
+		panic(v)
+	} else if v != nil {
+		r.PanicHandler(w, req, p, v)
+	}
+}
+
+// DefaultPanicHandler logs the recovered value along
+// with the stack trace and responds with HTTP 500.
+func DefaultPanicHandler(w http.ResponseWriter, r *http.Request, p Params, v interface{}) {
+	log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// redirect redirects the request to the given path, preserving the
+// query string. GET and HEAD requests are redirected with HTTP 301,
+// other methods with HTTP 308 so the method and body are preserved.
+func redirect(w http.ResponseWriter, req *http.Request, u string) {
+	c := http.StatusMovedPermanently
+
+	if req.Method != "GET" && req.Method != "HEAD" {
+		c = http.StatusPermanentRedirect
+	}
+
+	if req.URL.RawQuery != "" {
+		u += "?" + req.URL.RawQuery
+	}
+
+	http.Redirect(w, req, u, c)
+}
+
+// error writes the error response using the
+// most specific error handler available.
+func (r *Router) error(w http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case err == ErrRouteNotFound && r.NotFound != nil:
+		r.NotFound(w, req, err)
+
+	case err == ErrInvalidMethod && r.MethodNotAllowed != nil:
+		r.MethodNotAllowed(w, req, err)
+
+	case r.ErrorHandler != nil:
+		r.ErrorHandler(w, req, err)
+
+	default:
 		if e, ok := err.(*Error); ok {
 			w.WriteHeader(e.code)
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
+	}
+}
+
+// allow returns the Allow header value for the given route,
+// including the automatically handled methods.
+func (r *Router) allow(route *Route) string {
+	return strings.Join(r.methods(route), ", ")
+}
+
+// methods returns the sorted methods allowed for the route,
+// including automatically handled HEAD and OPTIONS requests.
+func (r *Router) methods(route *Route) []string {
+	m := route.Allow()
+
+	if _, exists := route.Handlers["HEAD"]; !exists && !r.DisableAutoHead {
+		if _, exists = route.Handlers["GET"]; exists {
+			m = append(m, "HEAD")
+		}
+	}
 
-		return
+	if _, exists := route.Handlers["OPTIONS"]; !exists && !r.DisableAutoOptions {
+		m = append(m, "OPTIONS")
 	}
 
-	h(w, req, p)
+	sort.Strings(m)
+
+	return m
+}
+
+// headResponseWriter discards the response body
+// when serving HEAD requests with GET handlers.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Write implements the io.Writer interface.
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
 }
 
 // VOID handler for testing.
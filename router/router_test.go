@@ -15,7 +15,15 @@
 package router
 
 import (
+	"io/ioutil"
+	"log"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -59,6 +67,1077 @@ func TestRuntimeRouter(t *testing.T) {
 	}
 }
 
+func TestRouterStaticPriority(t *testing.T) {
+	var matched string
+
+	handler := func(s string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, p Params) {
+			matched = s
+		}
+	}
+
+	r := &Router{}
+
+	for _, s := range []string{"/users/:id", "/users/me", "/users/me/settings", "/users/:id/posts", "/users/:id/posts/:post"} {
+		if err := r.Add("GET", s, handler(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct{ url, route, param, value string }{
+		{"/users/me", "/users/me", "", ""},
+		{"/users/123", "/users/:id", "id", "123"},
+		{"/users/me/settings", "/users/me/settings", "", ""},
+		{"/users/me/posts", "/users/:id/posts", "id", "me"},
+		{"/users/me/posts/1", "/users/:id/posts/:post", "post", "1"},
+	}
+
+	for _, c := range tests {
+		req, err := http.NewRequest("GET", c.url, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, p, err := r.Get(req)
+
+		if err != nil {
+			t.Fatalf("%s: %s", c.url, err)
+		}
+
+		h(nil, req, p)
+
+		if matched != c.route {
+			t.Fatalf("%s: matched %s, expected %s", c.url, matched, c.route)
+		} else if c.param != "" && p.Get(c.param) != c.value {
+			t.Fatalf("%s: unexpected value %q", c.url, p.Get(c.param))
+		}
+	}
+
+	// Generated routes
+	req, err := http.NewRequest("GET", "/users/me", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []*Router{routes, compiledRoutes} {
+		if _, p, err := r.Get(req); err != nil {
+			t.Fatal(err)
+		} else if p.Get("user") != "me" {
+			t.Fatal("unexpected value")
+		}
+	}
+}
+
+func TestRouterWildcard(t *testing.T) {
+	r := &Router{}
+	r.AddValidator("filepath", func(s string) bool {
+		return !strings.Contains(s, "..")
+	})
+
+	if err := r.Add("GET", "/static/*filepath", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Add("GET", "/static/:file", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Add("GET", "/static/*filepath/x", exampleHandler); err != ErrInvalidPath {
+		t.Fatal("expected invalid path error")
+	}
+
+	tests := []struct{ url, param, value string }{
+		{"/static/css/site.css", "filepath", "css/site.css"},
+		{"/static/site.css", "file", "site.css"},
+		{"/static/../../etc/passwd", "", ""},
+		{"/static", "", ""},
+	}
+
+	for _, c := range tests {
+		req, err := http.NewRequest("GET", c.url, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, p, err := r.Get(req)
+
+		if c.param == "" {
+			if err != ErrRouteNotFound {
+				t.Fatalf("%s: expected route not found error", c.url)
+			}
+
+			continue
+		} else if err != nil {
+			t.Fatalf("%s: %s", c.url, err)
+		} else if p.Get(c.param) != c.value {
+			t.Fatalf("%s: unexpected value %q", c.url, p.Get(c.param))
+		}
+	}
+
+	// Generated routes
+	req, err := http.NewRequest("GET", "/files/a/b/c.txt", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []*Router{routes, compiledRoutes} {
+		if _, p, err := r.Get(req); err != nil {
+			t.Fatal(err)
+		} else if p.Get("filepath") != "a/b/c.txt" {
+			t.Fatal("unexpected value")
+		}
+	}
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	r := &Router{}
+
+	if err := r.Add("POST", "/users/me", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Add("DELETE", "/users/me", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Add("GET", "/users/:id", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		r            *Router
+		method, url  string
+		code         int
+		allow, param string
+	}{
+		{r, "GET", "/users/me", http.StatusOK, "", "me"},
+		{r, "PUT", "/users/me", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, POST", ""},
+		{r, "OPTIONS", "/users/me", http.StatusNoContent, "DELETE, GET, HEAD, OPTIONS, POST", ""},
+		{r, "PUT", "/users/1", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{r, "GET", "/posts/1", http.StatusNotFound, "", ""},
+		{routes, "PUT", "/users/1", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{routes, "PUT", "/schemas/test", http.StatusOK, "", ""},
+		{compiledRoutes, "PUT", "/users/1", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{compiledRoutes, "PUT", "/schemas/test", http.StatusOK, "", ""},
+	}
+
+	for _, c := range tests {
+		req, err := http.NewRequest(c.method, c.url, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		c.r.ServeHTTP(w, req)
+
+		if w.Code != c.code {
+			t.Fatalf("%s %s: unexpected status code %d", c.method, c.url, w.Code)
+		} else if v := w.Header().Get("Allow"); v != c.allow {
+			t.Fatalf("%s %s: unexpected Allow header %q", c.method, c.url, v)
+		}
+
+		if c.param == "" {
+			continue
+		} else if _, p, err := c.r.Get(req); err != nil {
+			t.Fatal(err)
+		} else if p.Get("id") != c.param {
+			t.Fatal("unexpected value")
+		}
+	}
+}
+
+func TestRouterAutoOptionsHead(t *testing.T) {
+	r := &Router{}
+
+	if err := r.Add("GET", "/hello", func(w http.ResponseWriter, r *http.Request, p Params) {
+		w.Write([]byte("hello"))
+	}); err != nil {
+		t.Fatal(err)
+	} else if err = r.Add("POST", "/hello", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method      string
+		code        int
+		allow, body string
+	}{
+		{"GET", http.StatusOK, "", "hello"},
+		{"HEAD", http.StatusOK, "", ""},
+		{"OPTIONS", http.StatusNoContent, "GET, HEAD, OPTIONS, POST", ""},
+		{"PUT", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST", ""},
+	}
+
+	for _, c := range tests {
+		req, err := http.NewRequest(c.method, "/hello", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != c.code {
+			t.Fatalf("%s: unexpected status code %d", c.method, w.Code)
+		} else if v := w.Header().Get("Allow"); v != c.allow {
+			t.Fatalf("%s: unexpected Allow header %q", c.method, v)
+		} else if w.Body.String() != c.body {
+			t.Fatalf("%s: unexpected body %q", c.method, w.Body.String())
+		}
+	}
+
+	r.DisableAutoOptions = true
+	r.DisableAutoHead = true
+
+	for _, m := range []string{"HEAD", "OPTIONS"} {
+		req, err := http.NewRequest(m, "/hello", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%s: unexpected status code %d", m, w.Code)
+		} else if v := w.Header().Get("Allow"); v != "GET, POST" {
+			t.Fatalf("%s: unexpected Allow header %q", m, v)
+		}
+	}
+}
+
+func TestRouterErrorHandlers(t *testing.T) {
+	handler := func(s string) ErrorHandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, err error) {
+			e, ok := err.(*Error)
+
+			if !ok {
+				t.Fatal("unexpected error type")
+			}
+
+			w.WriteHeader(e.StatusCode())
+			w.Write([]byte(s + ": " + e.Error()))
+		}
+	}
+
+	r := &Router{}
+
+	if err := r.Add("GET", "/hello", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, url string
+		code        int
+		body        string
+	}{
+		{"GET", "/world", http.StatusNotFound, ""},
+		{"POST", "/hello", http.StatusMethodNotAllowed, ""},
+		{"GET", "/world", http.StatusNotFound, "not found: route not found"},
+		{"POST", "/hello", http.StatusMethodNotAllowed, "method not allowed: invalid HTTP method"},
+		{"GET", "", http.StatusBadRequest, "error: bad request"},
+		{"GET", "/world", http.StatusNotFound, "error: route not found"},
+	}
+
+	for i, c := range tests {
+		switch i {
+		case 2:
+			r.NotFound = handler("not found")
+			r.MethodNotAllowed = handler("method not allowed")
+			r.ErrorHandler = handler("error")
+
+		case 5:
+			r.NotFound = nil
+		}
+
+		req, err := http.NewRequest(c.method, c.url, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != c.code {
+			t.Fatalf("%s %s: unexpected status code %d", c.method, c.url, w.Code)
+		} else if w.Body.String() != c.body {
+			t.Fatalf("%s %s: unexpected body %q", c.method, c.url, w.Body.String())
+		}
+	}
+}
+
+func TestRouterPanicHandler(t *testing.T) {
+	var (
+		params Params
+		value  interface{}
+	)
+
+	r := &Router{
+		PanicHandler: func(w http.ResponseWriter, r *http.Request, p Params, v interface{}) {
+			params, value = p, v
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	}
+
+	if err := r.Add("GET", "/panic/:id", func(w http.ResponseWriter, r *http.Request, p Params) {
+		panic("oops")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "/panic/1", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status code %d", w.Code)
+	} else if value != "oops" {
+		t.Fatalf("unexpected value %v", value)
+	} else if params.Get("id") != "1" {
+		t.Fatal("unexpected params")
+	}
+
+	// HEAD requests served by the GET handler
+	value = nil
+	req.Method = "HEAD"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("HEAD: unexpected status code %d", w.Code)
+	} else if value != "oops" {
+		t.Fatalf("HEAD: unexpected value %v", value)
+	}
+
+	req.Method = "GET"
+
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	r.PanicHandler = DefaultPanicHandler
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status code %d", w.Code)
+	}
+
+	// Aborted handlers are re-panicked
+	if err := r.Add("GET", "/abort", func(w http.ResponseWriter, r *http.Request, p Params) {
+		panic(http.ErrAbortHandler)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("unexpected panic %v", v)
+		}
+	}()
+
+	req.URL.Path = "/abort"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	t.Fatal("expected abort panic")
+}
+
+func TestRouterSlashPolicy(t *testing.T) {
+	r := &Router{}
+
+	for _, s := range []string{"/blog", "/feeds/", "/users/:id"} {
+		if err := r.Add("GET", s, exampleHandler); err != nil {
+			t.Fatal(err)
+		} else if err = r.Add("POST", s, exampleHandler); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		policy      SlashPolicy
+		fixed       bool
+		method, url string
+		code        int
+		location    string
+	}{
+		{SlashLenient, false, "GET", "/blog/", http.StatusOK, ""},
+		{SlashLenient, false, "GET", "/feeds", http.StatusOK, ""},
+		{SlashStrict, false, "GET", "/blog", http.StatusOK, ""},
+		{SlashStrict, false, "GET", "/blog/", http.StatusNotFound, ""},
+		{SlashStrict, false, "GET", "/feeds/", http.StatusOK, ""},
+		{SlashStrict, false, "GET", "/feeds", http.StatusNotFound, ""},
+		{SlashRedirect, false, "GET", "/blog/?page=2", http.StatusMovedPermanently, "/blog?page=2"},
+		{SlashRedirect, false, "GET", "/feeds", http.StatusMovedPermanently, "/feeds/"},
+		{SlashRedirect, false, "POST", "/users/1/", http.StatusPermanentRedirect, "/users/1"},
+		{SlashRedirect, false, "GET", "/users/1", http.StatusOK, ""},
+		{SlashLenient, false, "GET", "/blog//./x/..", http.StatusNotFound, ""},
+		{SlashLenient, true, "GET", "/blog//./x/..", http.StatusMovedPermanently, "/blog"},
+		{SlashLenient, true, "POST", "/users/../users//1", http.StatusPermanentRedirect, "/users/1"},
+		{SlashLenient, true, "GET", "/nope/../../x", http.StatusNotFound, ""},
+		{SlashRedirect, true, "GET", "/blog/../feeds", http.StatusMovedPermanently, "/feeds/"},
+		{SlashRedirect, true, "GET", "/blog/../blog/?page=2", http.StatusMovedPermanently, "/blog?page=2"},
+	}
+
+	for _, c := range tests {
+		r.SlashPolicy = c.policy
+		r.RedirectFixedPath = c.fixed
+
+		req, err := http.NewRequest(c.method, c.url, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != c.code {
+			t.Fatalf("%s %s: unexpected status code %d", c.method, c.url, w.Code)
+		} else if v := w.Header().Get("Location"); v != c.location {
+			t.Fatalf("%s %s: unexpected Location header %q", c.method, c.url, v)
+		}
+	}
+
+	// Redirects stay on the host
+	r = &Router{SlashPolicy: SlashRedirect}
+
+	if err := r.Add("GET", "/*path", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		url      string
+		code     int
+		location string
+	}{
+		{"//evil.com/", http.StatusMovedPermanently, "/evil.com"},
+		{"//evil.com", http.StatusOK, ""},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, &http.Request{Method: "GET", URL: &url.URL{Path: c.url}})
+
+		if w.Code != c.code {
+			t.Fatalf("%s: unexpected status code %d", c.url, w.Code)
+		} else if v := w.Header().Get("Location"); v != c.location {
+			t.Fatalf("%s: unexpected Location header %q", c.url, v)
+		}
+	}
+}
+
+func TestRouterURL(t *testing.T) {
+	r := &Router{}
+	r.AddValidator("month", IsMonth)
+
+	if err := r.AddNamed("archive", "GET", "/blog/:month/:slug/", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.AddNamed("archive", "POST", "blog/:month/:slug/", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.AddNamed("archive", "GET", "/blog/:month", exampleHandler); err != ErrDuplicateName {
+		t.Fatal("expected duplicate name error")
+	} else if err = r.AddNamed("static", "GET", "/static/*filepath", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.AddNamed("index", "GET", "/", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.AddNamed("dots", "GET", "/dots/*path", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dot segments are rejected before validation
+	r.AddValidator("path", func(string) bool { return true })
+
+	tests := []struct {
+		name   string
+		params []string
+		url    string
+		err    error
+	}{
+		{"index", nil, "/", nil},
+		{"archive", []string{"02", "hello world"}, "/blog/02/hello%20world/", nil},
+		{"static", []string{"css/a b.css"}, "/static/css/a%20b.css", nil},
+		{"archive", []string{"02"}, "", ErrParamCount},
+		{"archive", []string{"02", "a", "b"}, "", ErrParamCount},
+		{"archive", []string{"", "a"}, "", ErrInvalidParam},
+		{"archive", []string{"13", "a"}, "", ErrInvalidParam},
+		{"archive", []string{"02", ".."}, "", ErrInvalidParam},
+		{"static", []string{"/a"}, "", ErrInvalidParam},
+		{"static", []string{"a//b"}, "", ErrInvalidParam},
+		{"static", []string{"x/../y"}, "", ErrInvalidParam},
+		{"static", []string{"a/./b"}, "", ErrInvalidParam},
+		{"dots", []string{"x/../y"}, "", ErrInvalidParam},
+		{"dots", []string{".."}, "", ErrInvalidParam},
+		{"dots", []string{"x/y"}, "/dots/x/y", nil},
+		{"unknown", nil, "", ErrUnknownRoute},
+	}
+
+	for _, c := range tests {
+		if u, err := r.URL(c.name, c.params...); err != c.err {
+			t.Fatalf("%s: unexpected error %v", c.name, err)
+		} else if u != c.url {
+			t.Fatalf("%s: unexpected URL %q", c.name, u)
+		}
+	}
+
+	// Generated routes
+	if u, err := routesSchemaURL("test"); err != nil {
+		t.Fatal(err)
+	} else if u != "/schemas/test" {
+		t.Fatalf("unexpected URL %q", u)
+	}
+}
+
+func TestRouterRemoveReplace(t *testing.T) {
+	r := &Router{}
+
+	if err := r.Mount("/", routes); err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+	h := func(w http.ResponseWriter, req *http.Request, p Params) {
+		called = true
+	}
+
+	if err := r.Replace("GET", "/users/:user", h); err != nil {
+		t.Fatal(err)
+	} else if err = r.Replace("GET", "/users/:id", h); err != ErrNotRegistered {
+		t.Fatalf("unexpected error %v", err)
+	} else if err = r.Replace("POST", "/users/:user", h); err != ErrNotRegistered {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), &http.Request{Method: "GET", URL: &url.URL{Path: "/users/test"}})
+
+	if !called {
+		t.Fatal("replaced handler not called")
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		err    error
+	}{
+		{"GET", "/testing/hello/world", nil},
+		{"GET", "/testing/hello/world", ErrNotRegistered},
+		{"GET", "/testing/hello", ErrNotRegistered},
+		{"GET", "/files/*path", ErrNotRegistered},
+		{"GET", "/files/*filepath", nil},
+		{"GET", "/schemas/:schema", nil},
+		{"PUT", "/schemas/:schema", nil},
+		{"POST", "/schemas/:schema", nil},
+		{"PATCH", "/schemas/:schema", nil},
+		{"DELETE", "/schemas/:schema", nil},
+		{"GET", "/schemas//", ErrInvalidPath},
+	}
+
+	for _, c := range tests {
+		if err := r.Remove(c.method, c.path); err != c.err {
+			t.Fatalf("%s %s: unexpected error %v", c.method, c.path, err)
+		}
+	}
+
+	root := r.load().root
+
+	if _, exists := root.Children["testing/hello/world"]; exists {
+		t.Fatal("expected collapsed static route to be pruned")
+	} else if _, exists = root.Children["files"]; exists {
+		t.Fatal("expected wildcard route to be pruned")
+	} else if c := root.Children["schemas"].Child; c == nil || len(c.Handlers) != 0 || c.Name != "" {
+		t.Fatal("expected parameter route to be kept without handlers")
+	} else if _, err := r.URL("schema", "test"); err != ErrUnknownRoute {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, u := range []string{"/testing/hello/world", "/files/a", "/schemas/test"} {
+		req, err := http.NewRequest("GET", u, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		} else if _, _, err = r.Get(req); err != ErrRouteNotFound {
+			t.Fatalf("%s: unexpected error %v", u, err)
+		}
+	}
+
+	if _, _, err := r.Get(&http.Request{Method: "GET", URL: &url.URL{Path: "/schemas/test/archives/2016/01/02"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Original routes are unmodified
+	if _, exists := routes.Root.Children["testing/hello/world"]; !exists {
+		t.Fatal("expected mounted routes to be unmodified")
+	}
+
+	r = &Router{}
+
+	if err := r.Add("GET", "/a/b", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Remove("GET", "/a/b"); err != nil {
+		t.Fatal(err)
+	} else if r.load().root != nil {
+		t.Fatal("expected empty routes tree")
+	}
+}
+
+func TestRouterConflicts(t *testing.T) {
+	r := &Router{}
+	s := &Router{}
+
+	if err := r.Add("GET", "/a/:x/b", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Add("GET", "/files/*filepath", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = s.Add("GET", "/d", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Mount("/c", s); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path string
+		err          *ConflictError
+	}{
+		{"GET", "/a/:y/c", &ConflictError{ErrParamConflict, "GET", "/a/:y/c", "/a/:x/b"}},
+		{"GET", "/files/*path", &ConflictError{ErrParamConflict, "GET", "/files/*path", "/files/*filepath"}},
+		{"GET", "/a/$x/b/", &ConflictError{ErrDuplicateRoute, "GET", "/a/:x/b/", "/a/:x/b"}},
+		{"GET", "/c/d", &ConflictError{ErrShadowedRoute, "GET", "/c/d", "/c/d"}},
+		{"POST", "/a/:x/b/", &ConflictError{ErrSlashConflict, "POST", "/a/:x/b/", "/a/:x/b"}},
+		{"POST", "/a/:x/b", nil},
+		{"GET", "/a/:x/c", nil},
+	}
+
+	for _, c := range tests {
+		err := r.Add(c.method, c.path, exampleHandler)
+
+		if c.err == nil {
+			if err != nil {
+				t.Fatalf("%s %s: unexpected error %v", c.method, c.path, err)
+			}
+
+			continue
+		}
+
+		if e, ok := err.(*ConflictError); !ok || *e != *c.err {
+			t.Fatalf("%s %s: unexpected error %v", c.method, c.path, err)
+		}
+	}
+
+	// Conflicting routes aren't added
+	req, err := http.NewRequest("GET", "/a/1/c", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if _, p, err := r.Get(req); err != nil || p.Get("x") != "1" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Mounted routes
+	s = &Router{}
+
+	if err := s.Add("GET", "/:y/c", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Mount("/a", s); err == nil {
+		t.Fatal("expected conflict error")
+	} else if e, ok := err.(*ConflictError); !ok || *e != (ConflictError{ErrParamConflict, "", "/a/:y/c", "/a/:x/b"}) {
+		t.Fatalf("unexpected error %v", err)
+	} else if err = r.Mount("/v1", routes); err != nil {
+		t.Fatal(err)
+	} else if err = r.Mount("/v1", routes); err == nil {
+		t.Fatal("expected conflict error")
+	} else if e, ok := err.(*ConflictError); !ok || e.Err != ErrDuplicateRoute {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	s = &Router{}
+
+	if err := s.Add("PUT", "/:x/b/", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Mount("/a", s); err == nil {
+		t.Fatal("expected conflict error")
+	} else if e, ok := err.(*ConflictError); !ok || *e != (ConflictError{ErrSlashConflict, "", "/a/:x/b/", "/a/:x/b"}) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRouterValidators(t *testing.T) {
+	r := &Router{}
+
+	if err := r.Add("GET", "/archives/:month/:slug", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Verify(); err == nil {
+		t.Fatal("expected validator error")
+	} else if e, ok := err.(*ValidatorError); !ok || *e != (ValidatorError{"month", "/archives/:month/:slug"}) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Validators added after routes
+	r.AddValidator("month", IsMonth)
+	r.AddValidator(":slug", func(s string) bool { return s != "draft" })
+
+	tests := []struct {
+		url string
+		err error
+	}{
+		{"/archives/02/hello", nil},
+		{"/archives/13/hello", ErrRouteNotFound},
+		{"/archives/02/draft", ErrRouteNotFound},
+	}
+
+	for _, c := range tests {
+		req, err := http.NewRequest("GET", c.url, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		} else if _, _, err = r.Get(req); err != c.err {
+			t.Fatalf("%s: unexpected error %v", c.url, err)
+		}
+	}
+
+	if err := r.Verify(); err != nil {
+		t.Fatal(err)
+	} else if err = routes.Verify(); err == nil {
+		t.Fatal("expected validator error")
+	} else if e, ok := err.(*ValidatorError); !ok || *e != (ValidatorError{"filepath", "/files/*filepath"}) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRouterWalk(t *testing.T) {
+	r := &Router{}
+
+	if err := r.Mount("/v1", routes); err != nil {
+		t.Fatal(err)
+	} else if err = r.Add("GET", "/v1/users/:user/posts/", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	l := r.Routes()
+
+	if len(l) != 14 {
+		t.Fatalf("unexpected number of routes %d", len(l))
+	}
+
+	tests := []RouteInfo{
+		{"GET", "/v1", nil},
+		{"GET", "/v1/files/*filepath", []string{"filepath"}},
+		{"DELETE", "/v1/schemas/:schema", []string{"schema"}},
+		{"GET", "/v1/schemas/:schema", []string{"schema"}},
+		{"PATCH", "/v1/schemas/:schema", []string{"schema"}},
+		{"POST", "/v1/schemas/:schema", []string{"schema"}},
+		{"PUT", "/v1/schemas/:schema", []string{"schema"}},
+		{"GET", "/v1/schemas/:schema/archives/:year/:month/:day", []string{"schema", "year", "month", "day"}},
+		{"GET", "/v1/static/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q/r/s/t/u", nil},
+		{"GET", "/v1/testing/hello/world", nil},
+		{"GET", "/v1/users/me/:tab", []string{"tab"}},
+		{"GET", "/v1/users/:user", []string{"user"}},
+		{"GET", "/v1/users/:user/posts/", []string{"user"}},
+	}
+
+	// Skip the nofunc route
+	for i, c := range append(l[:2:2], l[3:]...) {
+		if c.Method != tests[i].Method || c.Pattern != tests[i].Pattern || strings.Join(c.Params, ",") != strings.Join(tests[i].Params, ",") {
+			t.Fatalf("unexpected route %v; expected %v", c, tests[i])
+		}
+	}
+
+	// Walking stops on error
+	var n int
+
+	if err := r.Walk(func(m, u string, params []string, h HandlerFunc) error {
+		if n++; n == 2 {
+			return ErrInvalidRoute
+		}
+
+		return nil
+	}); err != ErrInvalidRoute || n != 2 {
+		t.Fatalf("unexpected error %v", err)
+	} else if l = (&Router{}).Routes(); len(l) != 0 {
+		t.Fatal("expected no routes")
+	}
+}
+
+func TestRouterMatch(t *testing.T) {
+	r := &Router{}
+	compiled := &Router{
+		Matcher:    compiledRoutes.Matcher,
+		MaxParams:  compiledRoutes.MaxParams,
+		Validators: compiledRoutes.Validators,
+		Names:      compiledRoutes.Names,
+	}
+
+	if err := r.Mount("/", routes); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []*Router{r, compiled} {
+		if err := r.Add("GET", "/blog/:slug/", exampleHandler); err != nil {
+			t.Fatal(err)
+		} else if err = r.Add("POST", "/users/me", exampleHandler); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		method, path string
+		pattern      string
+		name         string
+		allow        string
+		err          error
+	}{
+		{"GET", "/schemas/test", "/schemas/:schema", "schema", "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT", nil},
+		{"OPTIONS", "/schemas/test", "/schemas/:schema", "schema", "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT", ErrInvalidMethod},
+		{"GET", "/blog/test", "/blog/:slug/", "", "GET, HEAD, OPTIONS", nil},
+		{"GET", "/files/a/b", "/files/*filepath", "", "GET, HEAD, OPTIONS", nil},
+		{"GET", "/", "/", "", "GET, HEAD, OPTIONS", nil},
+		{"GET", "/users/me", "/users/:user", "", "GET, HEAD, OPTIONS, POST", nil},
+		{"get", "/blog/test", "/blog/:slug/", "", "GET, HEAD, OPTIONS", nil},
+		{"get", "/files/a/b", "/files/*filepath", "", "GET, HEAD, OPTIONS", nil},
+		{"GET", "/unknown", "", "", "", ErrRouteNotFound},
+	}
+
+	for _, r := range []*Router{r, compiled} {
+		for _, c := range tests {
+			_, _, i, err := r.Match(c.method, c.path)
+
+			if err != c.err {
+				t.Fatalf("%s %s: unexpected error %v", c.method, c.path, err)
+			} else if c.pattern == "" {
+				if i != nil {
+					t.Fatalf("%s %s: unexpected match %v", c.method, c.path, i)
+				}
+
+				continue
+			} else if i.Pattern != c.pattern || i.Name != c.name || strings.Join(i.Allow(), ", ") != c.allow {
+				t.Fatalf("%s %s: unexpected match %q %q %v", c.method, c.path, i.Pattern, i.Name, i.Allow())
+			}
+		}
+	}
+
+	// Match returns copies of the route details
+	_, _, i, _ := r.Match("GET", "/schemas/test")
+	i.Pattern = "/changed"
+
+	if _, _, i, _ = r.Match("GET", "/schemas/test"); i.Pattern != "/schemas/:schema" {
+		t.Fatalf("unexpected pattern %q", i.Pattern)
+	}
+
+	// Handlers served by the router
+	var pattern string
+
+	r.Use(func(h HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, p Params) {
+			pattern = p.Pattern()
+			h(w, req, p)
+		}
+	})
+
+	if err := r.Add("GET", "/blog/:slug/comments", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct{ method, url, pattern string }{
+		{"GET", "/blog/test/comments", "/blog/:slug/comments"},
+		{"HEAD", "/blog/test/comments", "/blog/:slug/comments"},
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), &http.Request{Method: c.method, URL: &url.URL{Path: c.url}})
+
+		if pattern != c.pattern {
+			t.Fatalf("%s %s: unexpected pattern %q", c.method, c.url, pattern)
+		}
+	}
+
+	_, p, i, err := r.Match("GET", shortParam)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if i.Pattern != "/schemas/:schema/archives/:year/:month/:day" || p.Get("year") != "2015" {
+		t.Fatalf("unexpected match %q", i.Pattern)
+	}
+}
+
+func TestRouterMatcher(t *testing.T) {
+	c := &Route{Pattern: "/users/:user", Handlers: Handlers{"GET": exampleHandler}}
+	r := &Router{
+		Matcher: func(m, u string, p Params) (*Route, HandlerFunc, Params) {
+			if !strings.HasPrefix(u, "users/") {
+				return nil, nil, nil
+			} else if m != "GET" {
+				return c, nil, nil
+			}
+
+			return c, exampleHandler, p.Append("user", u[6:])
+		},
+	}
+
+	if err := r.Add("POST", "/users/:id", exampleHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path string
+		pattern      string
+		user         string
+		err          error
+	}{
+		{"GET", "/users/test/", "/users/:user", "test", nil},
+		{"POST", "/users/test", "/users/:id", "", nil},
+		{"PUT", "/users/test", "/users/:user", "", ErrInvalidMethod},
+		{"GET", "/unknown", "", "", ErrRouteNotFound},
+	}
+
+	for _, c := range tests {
+		_, p, i, err := r.Match(c.method, c.path)
+
+		if err != c.err {
+			t.Fatalf("%s %s: unexpected error %v", c.method, c.path, err)
+		} else if c.pattern == "" {
+			continue
+		} else if i.Pattern != c.pattern || p.Get("user") != c.user {
+			t.Fatalf("%s %s: unexpected match %q %v", c.method, c.path, i.Pattern, p)
+		}
+	}
+}
+
+func TestRouterCompiled(t *testing.T) {
+	r := &Router{Matcher: compiledRoutes.Matcher}
+
+	if err := r.Add("POST", "/users/:id", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if err = new(Router).Mount("/", compiledRoutes); err != ErrCompiledRoutes {
+		t.Fatalf("unexpected mount error %v", err)
+	}
+
+	tests := []struct {
+		method, path string
+		err          *ConflictError
+	}{
+		{"GET", "/testing/hello/world", &ConflictError{ErrDuplicateRoute, "GET", "/testing/hello/world", "/testing/hello/world"}},
+		{"GET", "/users/:user", &ConflictError{ErrDuplicateRoute, "GET", "/users/:user", "/users/:user"}},
+		{"GET", "/files/x", &ConflictError{ErrShadowedRoute, "GET", "/files/x", "/files/*filepath"}},
+		{"POST", "/testing/hello/world", nil},
+	}
+
+	for _, c := range tests {
+		err := r.Add(c.method, c.path, exampleHandler)
+
+		if c.err == nil {
+			if err != nil {
+				t.Fatalf("%s %s: %s", c.method, c.path, err)
+			}
+		} else if e, ok := err.(*ConflictError); !ok || *e != *c.err {
+			t.Fatalf("%s %s: unexpected error %v", c.method, c.path, err)
+		}
+	}
+
+	for _, c := range []struct {
+		method, path string
+		err          error
+	}{
+		{"GET", "/users/:user", ErrCompiledRoutes},
+		{"GET", "/unknown", ErrNotRegistered},
+		{"POST", "/users/:id", nil},
+	} {
+		if err := r.Replace(c.method, c.path, exampleHandler); err != c.err {
+			t.Fatalf("%s %s: unexpected replace error %v", c.method, c.path, err)
+		} else if err = r.Remove(c.method, c.path); err != c.err {
+			t.Fatalf("%s %s: unexpected remove error %v", c.method, c.path, err)
+		}
+	}
+}
+
+func TestRouterCompiledRoutes(t *testing.T) {
+	l, c := routes.Routes(), compiledRoutes.Routes()
+
+	if len(l) == 0 || len(c) != len(l) {
+		t.Fatalf("unexpected number of routes %d", len(c))
+	}
+
+	for i, v := range l {
+		if c[i].Method != v.Method || c[i].Pattern != v.Pattern || strings.Join(c[i].Params, ",") != strings.Join(v.Params, ",") {
+			t.Fatalf("unexpected route %v, expected %v", c[i], v)
+		}
+	}
+
+	if err := compiledRoutes.Verify(); err == nil {
+		t.Fatal("expected validator error")
+	} else if e, ok := err.(*ValidatorError); !ok || *e != (ValidatorError{"filepath", "/files/*filepath"}) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := &Router{Matcher: compiledRoutes.Matcher, Compiled: compiledRoutes.Compiled}
+
+	if err := r.Add("GET", "/a/:id", exampleHandler); err != nil {
+		t.Fatal(err)
+	} else if l = r.Routes(); len(l) != len(c)+1 || l[0].Pattern != "/" || l[1].Pattern != "/a/:id" {
+		t.Fatalf("unexpected routes %v", l)
+	}
+}
+
+func TestRouterConcurrency(t *testing.T) {
+	r := &Router{}
+
+	if err := r.Mount("/", routes); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				u := "/concurrent/" + strconv.Itoa(i) + "/" + strconv.Itoa(j)
+
+				if err := r.AddNamed(u, "GET", u+"/:id", exampleHandler); err != nil {
+					t.Error(err)
+				}
+
+				r.AddValidator("v"+strconv.Itoa(i), IsDay)
+			}
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				for _, u := range []string{shortParam, longParam, longStatic} {
+					req, err := http.NewRequest("GET", u, nil)
+
+					if err != nil {
+						t.Error(err)
+					} else if _, _, err = r.Get(req); err != nil {
+						t.Error(err)
+					}
+				}
+
+				r.URL("schema", "test")
+				r.ServeHTTP(httptest.NewRecorder(), &http.Request{Method: "GET", URL: &url.URL{Path: "/concurrent/1/1/1"}})
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 100; j++ {
+			u := "/concurrent/" + strconv.Itoa(i) + "/" + strconv.Itoa(j)
+			req, err := http.NewRequest("GET", u+"/1", nil)
+
+			if err != nil {
+				t.Fatal(err)
+			} else if _, _, err = r.Get(req); err != nil {
+				t.Fatalf("%s: %s", u, err)
+			} else if v, err := r.URL(u, "1"); err != nil || v != u+"/1" {
+				t.Fatalf("%s: unexpected URL %q", u, v)
+			}
+		}
+	}
+}
+
 func TestRouter(t *testing.T) {
 	req, err := http.NewRequest("GET", shortParam, nil)
 
@@ -152,3 +1231,28 @@ func BenchmarkRouterMultiParam(b *testing.B) {
 		routes.Get(req)
 	}
 }
+
+func BenchmarkRouterServeShortParam(b *testing.B) {
+	benchmarkServe(b, shortParam)
+}
+
+func BenchmarkRouterServeLongParam(b *testing.B) {
+	benchmarkServe(b, longParam)
+}
+
+func benchmarkServe(b *testing.B, u string) {
+	req, err := http.NewRequest("GET", u, nil)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		routes.ServeHTTP(w, req)
+	}
+}
@@ -1,93 +1,73 @@
+// Code generated by routify; DO NOT EDIT.
+// Source: routes.yaml
+// SHA-256: a613f38411a68bc5d5cb7ed70807705afcd08ab1b735e652df5e1f09a10ec92b
+
 package router
 
 var routes = &Router{
-	Routes: Routes{
-		"DELETE": &Route{
-			Children: Routes{
-				"schemas": &Route{
-					Child: &Route{
-						Param:       "schema",
-						HandlerFunc: exampleHandler,
-					},
-				},
-			},
-		},
-		"PATCH": &Route{
-			Children: Routes{
-				"schemas": &Route{
-					Child: &Route{
-						Param:       "schema",
-						HandlerFunc: exampleHandler,
-					},
-				},
-			},
-		},
-		"POST": &Route{
-			Children: Routes{
-				"schemas": &Route{
-					Child: &Route{
-						Param:       "schema",
-						HandlerFunc: exampleHandler,
-					},
+	Root: &Route{
+		Children: Routes{
+			"/": &Route{
+				Pattern: "/",
+				Handlers: Handlers{
+					"GET": exampleHandler,
 				},
 			},
-		},
-		"PUT": &Route{
-			Children: Routes{
-				"schemas": &Route{
-					Child: &Route{
-						Param:       "schema",
-						HandlerFunc: exampleHandler,
+			"files": &Route{
+				Wildcard: &Route{
+					Param:   "filepath",
+					Pattern: "/files/*filepath",
+					Handlers: Handlers{
+						"GET": exampleHandler,
 					},
 				},
 			},
-		},
-		"GET": &Route{
-			Children: Routes{
-				"nofunc": &Route{
+			"nofunc": &Route{
+				Child: &Route{
+					Param: "a",
 					Child: &Route{
-						Param: "a",
+						Param: "b",
 						Child: &Route{
-							Param: "b",
+							Param: "c",
 							Child: &Route{
-								Param: "c",
+								Param: "d",
 								Child: &Route{
-									Param: "d",
+									Param: "e",
 									Child: &Route{
-										Param: "e",
+										Param: "f",
 										Child: &Route{
-											Param: "f",
+											Param: "g",
 											Child: &Route{
-												Param: "g",
+												Param: "h",
 												Child: &Route{
-													Param: "h",
+													Param: "i",
 													Child: &Route{
-														Param: "i",
+														Param: "j",
 														Child: &Route{
-															Param: "j",
+															Param: "k",
 															Child: &Route{
-																Param: "k",
+																Param: "l",
 																Child: &Route{
-																	Param: "l",
+																	Param: "m",
 																	Child: &Route{
-																		Param: "m",
+																		Param: "n",
 																		Child: &Route{
-																			Param: "n",
+																			Param: "o",
 																			Child: &Route{
-																				Param: "o",
+																				Param: "p",
 																				Child: &Route{
-																					Param: "p",
+																					Param: "q",
 																					Child: &Route{
-																						Param: "q",
+																						Param: "r",
 																						Child: &Route{
-																							Param: "r",
+																							Param: "s",
 																							Child: &Route{
-																								Param: "s",
+																								Param: "t",
 																								Child: &Route{
-																									Param: "t",
-																									Child: &Route{
-																										Param:       "u",
-																										HandlerFunc: exampleHandler,
+																									Param:   "u",
+																									Pattern: "/nofunc/:a/:b/:c/:d/:e/:f/:g/:h/:i/:j/:k/:l/:m/:n/:o/:p/:q/:r/:s/:t/:u",
+																									Handlers: Handlers{
+																										"GET": exampleHandler,
 																									},
 																								},
 																							},
@@ -110,25 +90,33 @@ var routes = &Router{
 						},
 					},
 				},
-				"static/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q/r/s/t/u": &Route{
-					HandlerFunc: exampleHandler,
-				},
-				"schemas": &Route{
-					Child: &Route{
-						Param:       "schema",
-						HandlerFunc: exampleHandler,
-						Children: Routes{
-							"archives": &Route{
+			},
+			"schemas": &Route{
+				Child: &Route{
+					Param:   "schema",
+					Name:    "schema",
+					Pattern: "/schemas/:schema",
+					Handlers: Handlers{
+						"DELETE": exampleHandler,
+						"GET":    exampleHandler,
+						"PATCH":  exampleHandler,
+						"POST":   exampleHandler,
+						"PUT":    exampleHandler,
+					},
+					Children: Routes{
+						"archives": &Route{
+							Child: &Route{
+								Param: "year",
+								Check: IsYear,
 								Child: &Route{
-									Param: "year",
-									Check: IsYear,
+									Param: "month",
+									Check: IsMonth,
 									Child: &Route{
-										Param: "month",
-										Check: IsMonth,
-										Child: &Route{
-											Param:       "day",
-											Check:       IsDay,
-											HandlerFunc: exampleHandler,
+										Param:   "day",
+										Check:   IsDay,
+										Pattern: "/schemas/:schema/archives/:year/:month/:day",
+										Handlers: Handlers{
+											"GET": exampleHandler,
 										},
 									},
 								},
@@ -136,18 +124,52 @@ var routes = &Router{
 						},
 					},
 				},
-				"testing/hello/world": &Route{
-					HandlerFunc: exampleHandler,
+			},
+			"static/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q/r/s/t/u": &Route{
+				Pattern: "/static/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q/r/s/t/u",
+				Handlers: Handlers{
+					"GET": exampleHandler,
 				},
-				"/": &Route{
-					HandlerFunc: exampleHandler,
+			},
+			"testing/hello/world": &Route{
+				Pattern: "/testing/hello/world",
+				Handlers: Handlers{
+					"GET": exampleHandler,
+				},
+			},
+			"users": &Route{
+				Children: Routes{
+					"me": &Route{
+						Child: &Route{
+							Param:   "tab",
+							Pattern: "/users/me/:tab",
+							Handlers: Handlers{
+								"GET": exampleHandler,
+							},
+						},
+					},
+				},
+				Child: &Route{
+					Param:   "user",
+					Pattern: "/users/:user",
+					Handlers: Handlers{
+						"GET": exampleHandler,
+					},
 				},
 			},
 		},
 	},
 	Validators: Validators{
-		"year":  IsYear,
-		"month": IsMonth,
 		"day":   IsDay,
+		"month": IsMonth,
+		"year":  IsYear,
+	},
+	Names: Names{
+		"schema": "/schemas/:schema",
 	},
 }
+
+// routesSchemaURL returns the URL of the schema route.
+func routesSchemaURL(schema string) (string, error) {
+	return routes.URL("schema", schema)
+}
@@ -0,0 +1,113 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"context"
+	"net/http"
+)
+
+// paramsKey is the request context key of the URL parameters.
+type paramsKey struct{}
+
+// ParamsFromContext returns the URL parameters stored in the
+// request context by standard library handler and middleware
+// adapters. The matched route pattern is available using the
+// Pattern method.
+func ParamsFromContext(ctx context.Context) Params {
+	p, _ := ctx.Value(paramsKey{}).(Params)
+
+	return p
+}
+
+// Std adapts the standard library handler to a handler function.
+// The URL parameters are stored in the request context and can
+// be retrieved using ParamsFromContext.
+func Std(h http.Handler) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p Params) {
+		if len(p) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, p))
+		}
+
+		h.ServeHTTP(w, r)
+	}
+}
+
+// StdFunc adapts the standard library handler function to a
+// handler function. The URL parameters are stored in the request
+// context and can be retrieved using ParamsFromContext.
+func StdFunc(f func(http.ResponseWriter, *http.Request)) HandlerFunc {
+	return Std(http.HandlerFunc(f))
+}
+
+// StdMiddleware adapts standard library middleware to middleware.
+// The URL parameters are stored in the request context and can
+// be retrieved using ParamsFromContext, e.g. for labelling
+// requests by route pattern.
+func StdMiddleware(mw func(http.Handler) http.Handler) Middleware {
+	return func(h HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, p Params) {
+			if len(p) > 0 {
+				r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, p))
+			}
+
+			mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h(w, r, p)
+			})).ServeHTTP(w, r)
+		}
+	}
+}
+
+// Handle adds a standard library handler for the given method and
+// path to the routes tree. The URL parameters are stored in the
+// request context and can be retrieved using ParamsFromContext.
+func (r *Router) Handle(m, u string, h http.Handler, mw ...Middleware) error {
+	if h == nil {
+		return ErrInvalidRoute
+	}
+
+	return r.Add(m, u, Std(h), mw...)
+}
+
+// HandleFunc adds a standard library handler function for the given
+// method and path to the routes tree. The URL parameters are stored
+// in the request context and can be retrieved using ParamsFromContext.
+func (r *Router) HandleFunc(m, u string, f func(http.ResponseWriter, *http.Request), mw ...Middleware) error {
+	if f == nil {
+		return ErrInvalidRoute
+	}
+
+	return r.Add(m, u, StdFunc(f), mw...)
+}
+
+// Handle adds a standard library handler for the given
+// method and path, relative to the group prefix.
+func (g *Group) Handle(m, u string, h http.Handler, mw ...Middleware) error {
+	if h == nil {
+		return ErrInvalidRoute
+	}
+
+	return g.Add(m, u, Std(h), mw...)
+}
+
+// HandleFunc adds a standard library handler function for
+// the given method and path, relative to the group prefix.
+func (g *Group) HandleFunc(m, u string, f func(http.ResponseWriter, *http.Request), mw ...Middleware) error {
+	if f == nil {
+		return ErrInvalidRoute
+	}
+
+	return g.Add(m, u, StdFunc(f), mw...)
+}
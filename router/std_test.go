@@ -0,0 +1,74 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStd(t *testing.T) {
+	stdHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", r.Header.Get("X-Test"))
+		w.Write([]byte(ParamsFromContext(r.Context()).Get("id")))
+	}
+
+	stdMiddleware := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("X-Test", "middleware")
+			w.Header().Set("X-Pattern", ParamsFromContext(r.Context()).Pattern())
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	r := &Router{}
+	r.Use(StdMiddleware(stdMiddleware))
+
+	if err := r.Handle("GET", "/handle/:id", http.HandlerFunc(stdHandler)); err != nil {
+		t.Fatal(err)
+	} else if err = r.Group("/group").HandleFunc("GET", "/:id", stdHandler); err != nil {
+		t.Fatal(err)
+	} else if err = r.Handle("GET", "/nil", nil); err != ErrInvalidRoute {
+		t.Fatal("expected invalid route error")
+	}
+
+	tests := []struct {
+		url, pattern string
+	}{
+		{"/handle/123", "/handle/:id"},
+		{"/group/123", "/group/:id"},
+	}
+
+	for _, c := range tests {
+		u := c.url
+		req, err := http.NewRequest("GET", u, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Body.String() != "123" {
+			t.Fatalf("%s: unexpected body %q", u, w.Body.String())
+		} else if v := w.Header().Get("X-Test"); v != "middleware" {
+			t.Fatalf("%s: unexpected header %q", u, v)
+		} else if v = w.Header().Get("X-Pattern"); v != c.pattern {
+			t.Fatalf("%s: unexpected pattern %q", u, v)
+		}
+	}
+}
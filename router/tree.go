@@ -0,0 +1,658 @@
+// Copyright Praegressus Limited. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// errStop stops walking the routes tree.
+var errStop = errors.New("stop")
+
+// tree holds the routes tree, validators and named routes.
+// Published trees are never modified; changes are made to
+// copies of the affected routes and maps.
+type tree struct {
+	root       *Route
+	validators Validators
+	names      Names
+
+	// Routes compiled for matching
+	index *node
+}
+
+// load returns the published routes, publishing
+// the initial routes if no routes have been published.
+func (r *Router) load() tree {
+	if t, ok := r.tree.Load().(*tree); ok {
+		return *t
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current()
+}
+
+// current returns the published routes, publishing the initial
+// routes if no routes have been published. The lock must be held.
+func (r *Router) current() tree {
+	if t, ok := r.tree.Load().(*tree); ok {
+		return *t
+	}
+
+	t := &tree{
+		root:       r.Root,
+		validators: r.Validators,
+		names:      r.Names,
+		index:      compile(r.Root, nil),
+	}
+
+	r.tree.Store(t)
+
+	return *t
+}
+
+// update applies the changes made by f to a copy of the routes,
+// publishing the copy unless f returns an error. Updates are
+// serialized, requests are served using the published routes.
+func (r *Router) update(f func(*tree) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev := r.current()
+	t := prev
+
+	if err := f(&t); err != nil {
+		return err
+	}
+
+	t.index = compile(t.root, prev.index)
+	r.tree.Store(&t)
+
+	return nil
+}
+
+// add adds a route to the routes tree, returning the route.
+// A ConflictError is returned if the route conflicts with
+// an existing route.
+func (t *tree) add(m, u string, h HandlerFunc) (*Route, error) {
+	if m == "" || u == "" || h == nil {
+		return nil, ErrInvalidRoute
+	}
+
+	p, err := segments(u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	m = strings.ToUpper(m)
+
+	var s *Route
+
+	t.root.lookup(p, func(c *Route) {
+		if _, exists := c.Handlers[m]; exists {
+			s = c
+		}
+	})
+
+	c, err := t.node(m, u, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	slash := hasTrailingSlash(u)
+
+	if _, exists := c.Handlers[m]; exists {
+		return nil, &ConflictError{ErrDuplicateRoute, m, pattern(p, slash), pattern(p, c.TrailingSlash)}
+	} else if s != nil {
+		return nil, &ConflictError{ErrShadowedRoute, m, pattern(p, slash), pattern(p, s.TrailingSlash)}
+	} else if len(c.Handlers) > 0 && c.TrailingSlash != slash {
+		return nil, &ConflictError{ErrSlashConflict, m, pattern(p, slash), pattern(p, c.TrailingSlash)}
+	}
+
+	c.Handlers[m] = h
+	c.TrailingSlash = slash
+	c.Pattern = pattern(p, slash)
+
+	return c, nil
+}
+
+// node returns a copy of the route for the given path, copying
+// the routes leading to it and adding routes as required. If
+// compact is true, trailing static path segments are optimized
+// into a single route. A ConflictError is returned if a parameter
+// name differs from the existing route.
+func (t *tree) node(m, u string, compact bool) (*Route, error) {
+	p, err := segments(u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.root = t.root.clone()
+	c := t.root
+
+	for i, l := 0, len(p); i < l; i++ {
+		// Wildcard; must be the final path segment
+		if p[i][0] == '*' {
+			if i != l-1 || len(p[i]) == 1 {
+				return nil, ErrInvalidPath
+			}
+
+			if c.Wildcard == nil {
+				c.Wildcard = &Route{
+					Param: p[i][1:],
+					Check: t.validators[p[i][1:]],
+				}
+			} else if c.Wildcard.Param != p[i][1:] {
+				return nil, &ConflictError{ErrParamConflict, m, pattern(p, hasTrailingSlash(u)), c.Wildcard.pattern(pattern(p[:i], false) + "/*" + c.Wildcard.Param)}
+			}
+
+			c.Wildcard = c.Wildcard.clone()
+			c = c.Wildcard
+
+			continue
+		}
+
+		// Parameter
+		if p[i][0] == ':' || p[i][0] == '$' {
+			if c.Child == nil {
+				c.Child = &Route{
+					Param: p[i][1:],
+					Check: t.validators[p[i][1:]],
+				}
+			} else if c.Child.Param != p[i][1:] {
+				return nil, &ConflictError{ErrParamConflict, m, pattern(p, hasTrailingSlash(u)), c.Child.pattern(pattern(p[:i], false) + "/:" + c.Child.Param)}
+			}
+
+			c.Child = c.Child.clone()
+			c = c.Child
+
+			continue
+		}
+
+		v := p[i]
+
+		if compact {
+			var n int
+
+			v, n = staticPath(p[i:])
+			i += n
+		}
+
+		c.Children[v] = c.Children[v].clone()
+		c = c.Children[v]
+	}
+
+	return c, nil
+}
+
+// remove removes the handler for the given method and path,
+// pruning routes left without handlers or descendants.
+func (t *tree) remove(m, u string) error {
+	p, err := segments(u)
+
+	if err != nil {
+		return err
+	}
+
+	var n string
+
+	root, ok := t.root.modify(p, func(c *Route) bool {
+		if _, exists := c.Handlers[m]; !exists {
+			return false
+		}
+
+		delete(c.Handlers, m)
+
+		if len(c.Handlers) == 0 {
+			n = c.Name
+			c.Name = ""
+			c.Pattern = ""
+			c.TrailingSlash = false
+		}
+
+		return true
+	})
+
+	if !ok {
+		return ErrNotRegistered
+	}
+
+	t.root = root
+
+	if n != "" {
+		names := make(Names, len(t.names))
+
+		for k, v := range t.names {
+			if k != n {
+				names[k] = v
+			}
+		}
+
+		t.names = names
+	}
+
+	return nil
+}
+
+// replace replaces the handler for the given method and path.
+func (t *tree) replace(m, u string, h HandlerFunc) error {
+	if h == nil {
+		return ErrInvalidRoute
+	}
+
+	p, err := segments(u)
+
+	if err != nil {
+		return err
+	}
+
+	root, ok := t.root.modify(p, func(c *Route) bool {
+		if _, exists := c.Handlers[m]; !exists {
+			return false
+		}
+
+		c.Handlers[m] = h
+
+		return true
+	})
+
+	if !ok {
+		return ErrNotRegistered
+	}
+
+	t.root = root
+
+	return nil
+}
+
+// modify returns a copy of the route with f applied to a copy of the
+// descendant route for the given path segments, copying the routes
+// leading to it. Routes left without handlers or descendants are
+// pruned; nil is returned if the route itself is pruned. If the
+// descendant route doesn't exist or f returns false, the route
+// is returned unmodified and ok is false.
+func (r *Route) modify(p []string, f func(*Route) bool) (*Route, bool) {
+	if r == nil {
+		return nil, false
+	} else if len(p) == 0 {
+		c := r.clone()
+
+		if !f(c) {
+			return r, false
+		}
+
+		return c.prune(), true
+	}
+
+	switch p[0][0] {
+	case '*':
+		if len(p) != 1 || r.Wildcard == nil || r.Wildcard.Param != p[0][1:] {
+			return r, false
+		}
+
+		v, ok := r.Wildcard.modify(nil, f)
+
+		if !ok {
+			return r, false
+		}
+
+		c := r.clone()
+		c.Wildcard = v
+
+		return c.prune(), true
+
+	case ':', '$':
+		if r.Child == nil || r.Child.Param != p[0][1:] {
+			return r, false
+		}
+
+		v, ok := r.Child.modify(p[1:], f)
+
+		if !ok {
+			return r, false
+		}
+
+		c := r.clone()
+		c.Child = v
+
+		return c.prune(), true
+	}
+
+	// Optimized static path
+	if v, n := staticPath(p); n > 0 {
+		if c, ok := r.modifyChild(v, nil, f); ok {
+			return c, true
+		}
+	}
+
+	return r.modifyChild(p[0], p[1:], f)
+}
+
+// modifyChild applies modify to the static child route for the given key.
+func (r *Route) modifyChild(k string, p []string, f func(*Route) bool) (*Route, bool) {
+	v, exists := r.Children[k]
+
+	if !exists {
+		return r, false
+	} else if v, exists = v.modify(p, f); !exists {
+		return r, false
+	}
+
+	c := r.clone()
+
+	if v == nil {
+		delete(c.Children, k)
+	} else {
+		c.Children[k] = v
+	}
+
+	return c.prune(), true
+}
+
+// lookup calls f for each route under route r for the given path
+// segments, following both separate and optimized static routes.
+func (r *Route) lookup(p []string, f func(*Route)) {
+	if r == nil {
+		return
+	} else if len(p) == 0 {
+		f(r)
+
+		return
+	}
+
+	switch p[0][0] {
+	case '*':
+		if len(p) == 1 && r.Wildcard != nil && r.Wildcard.Param == p[0][1:] {
+			f(r.Wildcard)
+		}
+
+		return
+
+	case ':', '$':
+		if r.Child != nil && r.Child.Param == p[0][1:] {
+			r.Child.lookup(p[1:], f)
+		}
+
+		return
+	}
+
+	// Optimized static path
+	if v, n := staticPath(p); n > 0 {
+		if c, exists := r.Children[v]; exists {
+			f(c)
+		}
+	}
+
+	r.Children[p[0]].lookup(p[1:], f)
+}
+
+// walk calls f for each route with handlers under route r,
+// ordered by path, passing the route pattern relative to r
+// prefixed by the given prefix. Walking stops if f returns
+// an error, which is returned.
+func (r *Route) walk(prefix string, f func(string, *Route) error) error {
+	if len(r.Handlers) > 0 {
+		u := prefix
+
+		if u == "" {
+			u = "/"
+		} else if r.TrailingSlash {
+			u += "/"
+		}
+
+		if err := f(u, r); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range r.keys() {
+		u := prefix
+
+		// Root path
+		if k != "/" {
+			u += "/" + k
+		}
+
+		if err := r.Children[k].walk(u, f); err != nil {
+			return err
+		}
+	}
+
+	if r.Child != nil {
+		if err := r.Child.walk(prefix+"/:"+r.Child.Param, f); err != nil {
+			return err
+		}
+	}
+
+	if r.Wildcard != nil {
+		return r.Wildcard.walk(prefix+"/*"+r.Wildcard.Param, f)
+	}
+
+	return nil
+}
+
+// relink returns a copy of route r with the checks of parameter
+// and wildcard routes named n set to f, copying only the routes
+// leading to them. Route r is returned if no routes are named n.
+func (r *Route) relink(n string, f func(string) bool) *Route {
+	if r == nil {
+		return nil
+	}
+
+	c := r
+
+	if r.Param == n {
+		c = r.clone()
+		c.Check = f
+	}
+
+	for k, v := range r.Children {
+		if d := v.relink(n, f); d != v {
+			if c == r {
+				c = r.clone()
+			}
+
+			c.Children[k] = d
+		}
+	}
+
+	if d := r.Child.relink(n, f); d != r.Child {
+		if c == r {
+			c = r.clone()
+		}
+
+		c.Child = d
+	}
+
+	if d := r.Wildcard.relink(n, f); d != r.Wildcard {
+		if c == r {
+			c = r.clone()
+		}
+
+		c.Wildcard = d
+	}
+
+	return c
+}
+
+// verify returns a ValidatorError for the first parameter or
+// wildcard route under route r without a validator. The prefix
+// is the pattern of route r.
+func (r *Route) verify(prefix string) error {
+	if r.Param != "" && r.Check == nil {
+		return &ValidatorError{r.Param, r.pattern(prefix)}
+	}
+
+	for _, k := range r.keys() {
+		u := prefix
+
+		// Root path
+		if k != "/" {
+			u += "/" + k
+		}
+
+		if err := r.Children[k].verify(u); err != nil {
+			return err
+		}
+	}
+
+	if r.Child != nil {
+		if err := r.Child.verify(prefix + "/:" + r.Child.Param); err != nil {
+			return err
+		}
+	}
+
+	if r.Wildcard != nil {
+		return r.Wildcard.verify(prefix + "/*" + r.Wildcard.Param)
+	}
+
+	return nil
+}
+
+// keys returns the sorted keys of the static child routes.
+func (r *Route) keys() []string {
+	keys := make([]string, 0, len(r.Children))
+
+	for k := range r.Children {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// pattern returns the pattern of the first route
+// under route r, prefixed by the given prefix.
+func (r *Route) pattern(prefix string) string {
+	s := prefix
+
+	r.walk(prefix, func(u string, _ *Route) error {
+		s = u
+
+		return errStop
+	})
+
+	return s
+}
+
+// pattern returns the route pattern for the given path
+// segments, using the ":" parameter prefix.
+func pattern(p []string, slash bool) string {
+	if len(p) == 1 && p[0] == "/" {
+		return "/"
+	}
+
+	var s string
+
+	for _, v := range p {
+		if v[0] == '$' {
+			v = ":" + v[1:]
+		}
+
+		s += "/" + v
+	}
+
+	if slash {
+		s += "/"
+	}
+
+	return s
+}
+
+// prune returns nil if the route has no handlers or descendants.
+func (r *Route) prune() *Route {
+	if len(r.Handlers) == 0 && len(r.Children) == 0 && r.Child == nil && r.Wildcard == nil {
+		return nil
+	}
+
+	return r
+}
+
+// segments returns the path segments of the route path.
+func segments(u string) ([]string, error) {
+	if u == "/" {
+		return []string{"/"}, nil
+	}
+
+	p := strings.Split(stripSlashes(u), "/")
+
+	for _, v := range p {
+		if v == "" {
+			return nil, ErrInvalidPath
+		}
+	}
+
+	return p, nil
+}
+
+// clone returns a copy of the route which can be modified without
+// affecting the route, sharing the descendants of the route.
+// A new route is returned if the route is nil.
+func (r *Route) clone() *Route {
+	c := &Route{}
+
+	if r != nil {
+		*c = *r
+	}
+
+	c.Children = make(Routes, len(c.Children))
+	c.Handlers = make(Handlers, len(c.Handlers))
+
+	if r != nil {
+		for k, v := range r.Children {
+			c.Children[k] = v
+		}
+
+		for k, v := range r.Handlers {
+			c.Handlers[k] = v
+		}
+	}
+
+	return c
+}
+
+// with returns a copy of the names with the given named route.
+func (n Names) with(k, v string) Names {
+	c := make(Names, len(n)+1)
+
+	for a, b := range n {
+		c[a] = b
+	}
+
+	c[k] = v
+
+	return c
+}
+
+// with returns a copy of the validators with the given validator.
+func (v Validators) with(k string, f func(string) bool) Validators {
+	c := make(Validators, len(v)+1)
+
+	for a, b := range v {
+		c[a] = b
+	}
+
+	c[k] = f
+
+	return c
+}
@@ -14,6 +14,8 @@
 
 package router
 
+import "path"
+
 // IsYear tests if the string is a valid year (YYYY).
 func IsYear(s string) bool {
 	return len(s) == 4 &&
@@ -43,7 +45,7 @@ func IsDay(s string) bool {
 
 func staticPath(p []string) (string, int) {
 	for _, v := range p {
-		if v == "" || v[0] == ':' || v[0] == '$' {
+		if v == "" || v[0] == ':' || v[0] == '$' || v[0] == '*' {
 			return p[0], 0
 		}
 	}
@@ -52,7 +54,7 @@ func staticPath(p []string) (string, int) {
 	c := -1
 
 	for i, v := range p {
-		if v == "" || v[0] == ':' || v[0] == '$' {
+		if v == "" || v[0] == ':' || v[0] == '$' || v[0] == '*' {
 			break
 		}
 
@@ -81,9 +83,39 @@ func stripSlashes(s string) string {
 	}
 
 	// Tail
-	if l > 1 && s[l] == '/' {
+	if l > 0 && s[l] == '/' {
 		s = s[:l]
 	}
 
 	return s
 }
+
+func hasTrailingSlash(s string) bool {
+	return len(s) > 1 && s[len(s)-1] == '/'
+}
+
+// toggleSlash returns the cleaned path with the trailing slash
+// added or removed. Cleaned paths never start with "//", so the
+// result can't redirect to another host.
+func toggleSlash(c string) string {
+	if hasTrailingSlash(c) {
+		return c[:len(c)-1]
+	} else if c == "/" {
+		return c
+	}
+
+	return c + "/"
+}
+
+// cleanPath returns the canonical form of the URL path, resolving
+// "." and ".." elements and duplicate slashes. Trailing slashes
+// are preserved.
+func cleanPath(s string) string {
+	c := path.Clean("/" + s)
+
+	if c != "/" && hasTrailingSlash(s) {
+		c += "/"
+	}
+
+	return c
+}
@@ -15,13 +15,23 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"errors"
 	"flag"
 	"fmt"
+	"go/format"
+	"go/scanner"
+	"go/token"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/martingallagher/routify/router"
 	"gopkg.in/yaml.v2"
@@ -32,20 +42,80 @@ var (
 	outputFile      = flag.String("o", "routes.go", "Routes output file")
 	packageName     = flag.String("p", "", "Package name")
 	varName         = flag.String("v", "routes", "Variable name")
+	strict          = flag.Bool("strict", false, "Require validators for all route parameters")
+	mode            = flag.String("mode", "tree", "Output mode: tree (routes tree) or compiled (matcher function)")
+	check           = flag.Bool("check", false, "Exit with an error if the output file is stale instead of writing it")
+	local           = flag.Bool("local", false, "Generate routes within the router package, without the router qualifier")
 	errInvalidInput = errors.New("missing routes input file")
+
+	// Router level handlers; routes.yaml key -> router.Router field
+	hooks = map[string]string{
+		"notfound":         "NotFound",
+		"methodnotallowed": "MethodNotAllowed",
+		"errorhandler":     "ErrorHandler",
+		"panichandler":     "PanicHandler",
+	}
+
+	// Trailing slash policies; routes.yaml value -> router.SlashPolicy
+	slashPolicies = map[string]string{
+		"lenient":  "router.SlashLenient",
+		"strict":   "router.SlashStrict",
+		"redirect": "router.SlashRedirect",
+	}
 )
 
 type routemap map[string]*route
 
+// keys returns the sorted keys of the routes.
+func (m routemap) keys() []string {
+	s := make([]string, 0, len(m))
+
+	for k := range m {
+		s = append(s, k)
+	}
+
+	sort.Strings(s)
+
+	return s
+}
+
+// definition is the routes.yaml definition of a route.
+type definition struct {
+	path string
+	line int
+}
+
+// conflictError is a route conflict with routes.yaml line numbers.
+type conflictError struct {
+	*router.ConflictError
+	line, existing int
+}
+
+func (e *conflictError) Error() string {
+	return fmt.Sprintf("%s:%d: %s (line %d)", *inputFile, e.line, e.ConflictError, e.existing)
+}
+
 type routes struct {
-	params map[string]string
-	routes routemap
+	params  map[string]string
+	hooks   map[string]string
+	options map[string]string
+	names   map[string]string
+	root    *route
+
+	// Router level middleware
+	middleware []string
+	// Referenced packages; import path -> package name
+	imports map[string]string
 }
 
 type route struct {
-	child                *route
-	children             routemap
-	param, check, handle string
+	child, wildcard *route
+	children        routemap
+	handlers        map[string]string
+	defs            map[string]definition
+	param, check    string
+	name, pattern   string
+	slash           bool
 }
 
 func main() {
@@ -56,60 +126,230 @@ func main() {
 		log.Fatal("input filename is required (use -i flag)")
 	} else if *packageName == "" {
 		log.Fatal("package name is required (use -p flag)")
+	} else if *mode != "tree" && *mode != "compiled" {
+		log.Fatal("mode must be tree or compiled (use -mode flag)")
 	}
 
-	f, err := os.OpenFile(*outputFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	src, err := ioutil.ReadFile(*inputFile)
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer f.Close()
+	r, err := loadRoutes(src)
+
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	r, err := loadRoutes()
+	b, err := r.generate(src)
 
 	if err != nil {
 		log.Fatal(err)
+	} else if *check {
+		if c, err := ioutil.ReadFile(*outputFile); err != nil {
+			log.Fatal(err)
+		} else if !bytes.Equal(b, c) {
+			log.Fatalf("%s is stale: regenerate from %s", *outputFile, *inputFile)
+		}
+	} else if err = writeFile(*outputFile, b); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	fmt.Fprintf(f, `package %s 
+// generate returns the formatted Go routes file generated from
+// the routes.yaml contents. Map keys are sorted so the output
+// only changes with the routes.
+func (r *routes) generate(src []byte) ([]byte, error) {
+	var (
+		b        bytes.Buffer
+		m        *matcher
+		std, pkg = r.importSpecs()
+	)
 
-import "github.com/martingallagher/routify/router"
+	fmt.Fprintf(&b, "// Code generated by routify; DO NOT EDIT.\n// Source: %s\n// SHA-256: %x\n\n", filepath.ToSlash(*inputFile), sha256.Sum256(src))
 
-var %s = &router.Router{
-	Routes: router.Routes{
-`, *packageName, *varName)
+	if *mode == "compiled" {
+		if m = r.compile(); m.strings {
+			std = append(std, `"strings"`)
+			sort.Strings(std)
+		}
+	}
 
-	for k, v := range r.routes {
-		if len(v.children) == 0 {
-			continue
+	if !*local {
+		pkg = append([]string{routerImport}, pkg...)
+	}
+
+	fmt.Fprintf(&b, "package %s\n\n", *packageName)
+
+	if len(std)+len(pkg) == 1 {
+		fmt.Fprintf(&b, "import %s\n\n", append(std, pkg...)[0])
+	} else if len(std)+len(pkg) > 1 {
+		b.WriteString("import (\n")
+
+		for _, v := range std {
+			b.WriteString(v + "\n")
+		}
+
+		if len(std) > 0 && len(pkg) > 0 {
+			b.WriteString("\n")
 		}
 
-		r.writeRule(f, k, v)
+		for _, v := range pkg {
+			b.WriteString(v + "\n")
+		}
+
+		b.WriteString(")\n\n")
 	}
 
-	f.WriteString("\n},")
+	fmt.Fprintf(&b, "var %s = &router.Router{\n", *varName)
+
+	if m != nil {
+		fmt.Fprintf(&b, "Matcher: %sMatch,", *varName)
+
+		if n := r.root.depth(); n > 0 {
+			fmt.Fprintf(&b, "\nMaxParams: %d,", n)
+		}
+
+		if l := m.routes(); len(l) > 0 {
+			b.WriteString("\nCompiled: []*router.Route{\n")
+
+			for _, v := range l {
+				b.WriteString(v + ",\n")
+			}
+
+			b.WriteString("},")
+		}
+	} else {
+		b.WriteString("Root: &router.Route{\n")
+		r.writeRoute(&b, r.root)
+		b.WriteString("\n},")
+	}
 
 	if len(r.params) > 0 {
-		f.WriteString("\nValidators: router.Validators{\n")
+		b.WriteString("\nValidators: router.Validators{\n")
 
-		for k, v := range r.params {
-			fmt.Fprintf(f, "\"%s\": %s,\n", k[1:], v)
+		for _, k := range keys(r.params) {
+			fmt.Fprintf(&b, "\"%s\": %s,\n", k[1:], r.expr(r.params[k]))
 		}
 
-		f.WriteString("},")
+		b.WriteString("},")
+	}
+
+	for _, k := range keys(r.hooks) {
+		fmt.Fprintf(&b, "\n%s: %s,", k, r.expr(r.hooks[k]))
 	}
 
-	f.WriteString("\n}")
+	if len(r.names) > 0 {
+		b.WriteString("\nNames: router.Names{\n")
 
-	if err = f.Sync(); err != nil {
-		log.Fatal(err)
+		for _, k := range keys(r.names) {
+			fmt.Fprintf(&b, "%q: %q,\n", k, r.names[k])
+		}
+
+		b.WriteString("},")
+	}
+
+	for _, k := range keys(r.options) {
+		fmt.Fprintf(&b, "\n%s: %s,", k, r.options[k])
+	}
+
+	b.WriteString("\n}")
+
+	for _, k := range keys(r.names) {
+		r.writeURL(&b, k, r.names[k])
+	}
+
+	if m != nil {
+		m.WriteTo(&b)
+	}
+
+	if !*local {
+		return format.Source(b.Bytes())
+	}
+
+	return unqualify(b.Bytes())
+}
+
+// unqualify returns the formatted Go source without router
+// package qualifiers, for routes generated within the router
+// package. Comments and string literals are left untouched.
+func unqualify(src []byte) ([]byte, error) {
+	var (
+		b    bytes.Buffer
+		s    scanner.Scanner
+		last int
+		file = token.NewFileSet().AddFile("", -1, len(src))
+	)
+
+	s.Init(file, src, nil, 0)
+
+	for {
+		pos, tok, lit := s.Scan()
+
+		if tok == token.EOF {
+			break
+		} else if tok != token.IDENT || lit != "router" {
+			continue
+		}
+
+		i := file.Offset(pos)
+
+		if pos, tok, _ = s.Scan(); tok == token.PERIOD {
+			b.Write(src[last:i])
+			last = file.Offset(pos) + 1
+		}
+	}
+
+	b.Write(src[last:])
+
+	return format.Source(b.Bytes())
+}
+
+// writeFile atomically replaces the named file with the given
+// data, writing to a temporary file in the same directory first.
+func writeFile(name string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
 	}
+
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		return err
+	} else if err = os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// keys returns the sorted keys of the map.
+func keys(m map[string]string) []string {
+	s := make([]string, 0, len(m))
+
+	for k := range m {
+		s = append(s, k)
+	}
+
+	sort.Strings(s)
+
+	return s
 }
 
 func staticPath(p []string) (string, int) {
 	for _, v := range p {
-		if v == "" || v[0] == ':' || v[0] == '$' {
+		if v == "" || v[0] == ':' || v[0] == '$' || v[0] == '*' {
 			return p[0], 0
 		}
 	}
@@ -118,7 +358,7 @@ func staticPath(p []string) (string, int) {
 	c := -1
 
 	for i, v := range p {
-		if v[0] == ':' || v[0] == '$' {
+		if v[0] == ':' || v[0] == '$' || v[0] == '*' {
 			break
 		}
 
@@ -133,18 +373,18 @@ func staticPath(p []string) (string, int) {
 	return s, c
 }
 
-func (r *routes) add(method, path, handle string) error {
-	if _, exists := r.routes[method]; !exists {
-		r.routes[method] = &route{children: routemap{}}
-	}
-
+// add adds the route defined at the given routes.yaml line.
+// A conflictError is returned if the route conflicts with
+// a previously added route.
+func (r *routes) add(method, path, handle, name string, line int) error {
 	var (
-		p []string
-		c = r.routes[method]
+		p     []string
+		c     = r.root
+		slash = len(path) > 1 && path[len(path)-1] == '/'
 	)
 
 	if path != "/" {
-		p = strings.Split(path, "/")
+		p = strings.Split(strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/"), "/")
 	} else {
 		p = []string{"/"}
 	}
@@ -154,14 +394,37 @@ func (r *routes) add(method, path, handle string) error {
 			return router.ErrInvalidPath
 		}
 
+		// Wildcard; must be the final path segment
+		if p[i][0] == '*' {
+			if i != l-1 || len(p[i]) == 1 {
+				return router.ErrInvalidPath
+			}
+
+			if c.wildcard == nil {
+				if err := r.validator("$"+p[i][1:], path, line); err != nil {
+					return err
+				}
+
+				c.wildcard = newRoute(p[i][1:], r.params["$"+p[i][1:]])
+			} else if c.wildcard.param != p[i][1:] {
+				return conflict(router.ErrParamConflict, method, path, line, c.wildcard.first())
+			}
+
+			c = c.wildcard
+
+			continue
+		}
+
 		// Parameter
 		if p[i][0] == '$' {
-			if c.child == nil || c.child.param != p[i][1:] {
-				c.child = &route{
-					param:    p[i][1:],
-					check:    r.params[p[i]],
-					children: routemap{},
+			if c.child == nil {
+				if err := r.validator(p[i], path, line); err != nil {
+					return err
 				}
+
+				c.child = newRoute(p[i][1:], r.params[p[i]])
+			} else if c.child.param != p[i][1:] {
+				return conflict(router.ErrParamConflict, method, path, line, c.child.first())
 			}
 
 			c = c.child
@@ -174,92 +437,318 @@ func (r *routes) add(method, path, handle string) error {
 
 		// Allocate map for static routes
 		if _, exists := c.children[v]; !exists {
-			c.children[v] = &route{children: routemap{}}
+			c.children[v] = newRoute("", "")
 		}
 
 		c = c.children[v]
 	}
 
-	c.handle = handle
+	if d, exists := c.defs[method]; exists {
+		return conflict(router.ErrDuplicateRoute, method, path, line, d)
+	} else if len(c.defs) > 0 && c.slash != slash {
+		var d definition
+
+		// Earliest definition of the path
+		for _, v := range c.defs {
+			if d.line == 0 || v.line < d.line {
+				d = v
+			}
+		}
+
+		return conflict(router.ErrSlashConflict, method, path, line, d)
+	}
+
+	c.handlers[method] = handle
+	c.defs[method] = definition{path, line}
+	c.slash = slash
+	c.pattern = pattern(path)
+
+	if name == "" {
+		return nil
+	} else if v, exists := r.names[name]; exists && v != pattern(path) {
+		return fmt.Errorf("%s: %s", name, router.ErrDuplicateName)
+	}
+
+	c.name = name
+	r.names[name] = pattern(path)
 
 	return nil
 }
 
-func (r *routes) writeChild(f *os.File, c *route) {
-	fmt.Fprintf(f, "Child: &router.Route{\nParam: \"%s\",\n", c.param)
+// validator returns an error in strict mode if the
+// route parameter has no validator.
+func (r *routes) validator(param, path string, line int) error {
+	if _, exists := r.params[param]; exists || !*strict {
+		return nil
+	}
+
+	return fmt.Errorf("%s:%d: %s", *inputFile, line, &router.ValidatorError{
+		Param:   param[1:],
+		Pattern: pattern(path),
+	})
+}
 
+// first returns the first definition of the routes under route c.
+func (c *route) first() definition {
+	var d definition
+
+	for _, v := range c.defs {
+		if d.line == 0 || v.line < d.line {
+			d = v
+		}
+	}
+
+	for _, v := range []*route{c.child, c.wildcard} {
+		if v == nil {
+			continue
+		} else if e := v.first(); d.line == 0 || e.line < d.line {
+			d = e
+		}
+	}
+
+	for _, v := range c.children {
+		if e := v.first(); d.line == 0 || e.line < d.line {
+			d = e
+		}
+	}
+
+	return d
+}
+
+// conflict returns a conflictError for the route conflicting
+// with the existing route definition.
+func conflict(err error, method, path string, line int, d definition) error {
+	return &conflictError{
+		&router.ConflictError{
+			Err:      err,
+			Method:   method,
+			Pattern:  pattern(path),
+			Existing: pattern(d.path),
+		},
+		line,
+		d.line,
+	}
+}
+
+func (r *routes) writeRoute(b *bytes.Buffer, c *route) {
 	if c.check != "" {
-		fmt.Fprintf(f, "Check: %s,\n", c.check)
+		fmt.Fprintf(b, "Check: %s,\n", r.expr(c.check))
 	}
 
-	if c.handle != "" {
-		fmt.Fprintf(f, "HandlerFunc: %s,\n", c.handle)
+	if c.slash {
+		b.WriteString("TrailingSlash: true,\n")
+	}
+
+	if c.name != "" {
+		fmt.Fprintf(b, "Name: %q,\n", c.name)
+	}
+
+	if c.pattern != "" {
+		fmt.Fprintf(b, "Pattern: %q,\n", c.pattern)
+	}
+
+	if len(c.handlers) > 0 {
+		b.WriteString("Handlers: router.Handlers{\n")
+
+		for _, k := range keys(c.handlers) {
+			fmt.Fprintf(b, "\"%s\": %s,\n", k, r.expr(c.handlers[k]))
+		}
+
+		b.WriteString("},\n")
 	}
 
 	if len(c.children) > 0 {
-		r.writeChildren(f, c)
-	} else if c.child != nil {
-		r.writeChild(f, c.child)
+		r.writeChildren(b, c)
+	}
+
+	if c.child != nil {
+		r.writeParam(b, "Child", c.child)
 	}
 
-	f.WriteString("},\n")
+	if c.wildcard != nil {
+		r.writeParam(b, "Wildcard", c.wildcard)
+	}
+}
+
+func (r *routes) writeParam(b *bytes.Buffer, k string, c *route) {
+	fmt.Fprintf(b, "%s: &router.Route{\nParam: \"%s\",\n", k, c.param)
+	r.writeRoute(b, c)
+	b.WriteString("},\n")
 }
 
-func (r *routes) writeChildren(f *os.File, c *route) {
-	f.WriteString("Children: router.Routes{\n")
+func (r *routes) writeChildren(b *bytes.Buffer, c *route) {
+	b.WriteString("Children: router.Routes{\n")
 
-	for k, v := range c.children {
-		r.writeRule(f, k, v)
+	for _, k := range c.children.keys() {
+		r.writeRule(b, k, c.children[k])
 	}
 
-	f.WriteString("},\n")
+	b.WriteString("},\n")
+}
+
+func (r *routes) writeRule(b *bytes.Buffer, p string, c *route) {
+	fmt.Fprintf(b, "\"%s\": &router.Route{\n", p)
+	r.writeRoute(b, c)
+	b.WriteString("},\n")
 }
 
-func (r *routes) writeRule(f *os.File, p string, c *route) {
-	fmt.Fprintf(f, "\"%s\": &router.Route{\n", p)
+func (r *routes) writeURL(b *bytes.Buffer, n, p string) {
+	var a []string
 
-	if c.handle != "" {
-		fmt.Fprintf(f, "HandlerFunc: %s,\n", c.handle)
+	for _, v := range strings.Split(p, "/") {
+		if v == "" || v[0] != ':' && v[0] != '*' {
+			continue
+		} else if v = identifier(v[1:]); v == *varName {
+			v += "_"
+		}
+
+		a = append(a, v)
 	}
 
-	if len(c.children) > 0 {
-		r.writeChildren(f, c)
-	} else if c.child != nil {
-		r.writeChild(f, c.child)
+	// Prefixed by the variable name, exported along with the variable
+	fn := identifier(n)
+	c, l := utf8.DecodeRuneInString(fn)
+	fn = *varName + string(unicode.ToUpper(c)) + fn[l:] + "URL"
+	fmt.Fprintf(b, "\n\n// %s returns the URL of the %s route.\nfunc %s(", fn, n, fn)
+
+	if len(a) > 0 {
+		fmt.Fprintf(b, "%s string", strings.Join(a, ", "))
+		a = append([]string{""}, a...)
 	}
 
-	f.WriteString("},\n")
+	fmt.Fprintf(b, ") (string, error) {\nreturn %s.URL(%s%s)\n}", *varName, strconv.Quote(n), strings.Join(a, ", "))
 }
 
-func loadRoutes() (*routes, error) {
-	f, err := os.Open(*inputFile)
+// pattern returns the route pattern for the given path,
+// using the ":" parameter prefix of the router package.
+func pattern(path string) string {
+	p := strings.Split(strings.TrimPrefix(path, "/"), "/")
 
-	if err != nil {
-		return nil, err
+	for i, v := range p {
+		if v != "" && v[0] == '$' {
+			p[i] = ":" + v[1:]
+		}
 	}
 
-	defer f.Close()
+	return "/" + strings.Join(p, "/")
+}
+
+// identifier returns s as a Go identifier, camel casing
+// around invalid characters. Keywords are suffixed with "_".
+func identifier(s string) string {
+	var (
+		b     []rune
+		upper bool
+	)
 
-	b, err := ioutil.ReadAll(f)
+	for _, c := range s {
+		if !unicode.IsLetter(c) && c != '_' && (len(b) == 0 || !unicode.IsDigit(c)) {
+			upper = len(b) > 0
 
-	if err != nil {
-		return nil, err
+			continue
+		} else if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+
+		b = append(b, c)
 	}
 
-	f.Close()
+	if s = string(b); s == "" || token.Lookup(s).IsKeyword() {
+		s += "_"
+	}
+
+	return s
+}
 
+func newRoute(param, check string) *route {
+	return &route{
+		param:    param,
+		check:    check,
+		children: routemap{},
+		handlers: map[string]string{},
+		defs:     map[string]definition{},
+	}
+}
+
+// loadRoutes loads the routes from the routes.yaml contents.
+func loadRoutes(b []byte) (*routes, error) {
 	var m map[string]interface{}
 
-	if err = yaml.Unmarshal(b, &m); err != nil {
+	if err := yaml.Unmarshal(b, &m); err != nil {
 		return nil, err
 	}
 
 	var (
-		l [][]string
-		r = &routes{map[string]string{}, routemap{}}
+		l  [][]string
+		n  = map[string]string{}
+		mw = map[string][]string{}
+		ok bool
+		r  = &routes{
+			params:  map[string]string{},
+			hooks:   map[string]string{},
+			options: map[string]string{},
+			names:   map[string]string{},
+			imports: map[string]string{},
+			root:    newRoute("", ""),
+		}
 	)
 
 	for k, v := range m {
+		// Router options
+		switch o := strings.ToLower(k); o {
+		case "autooptions", "autohead", "redirectfixedpath":
+			b, ok := v.(bool)
+
+			if !ok {
+				return nil, fmt.Errorf("%s: expected boolean value", k)
+			}
+
+			switch {
+			case o == "autooptions" && !b:
+				r.options["DisableAutoOptions"] = "true"
+
+			case o == "autohead" && !b:
+				r.options["DisableAutoHead"] = "true"
+
+			case o == "redirectfixedpath" && b:
+				r.options["RedirectFixedPath"] = "true"
+			}
+
+			continue
+
+		case "trailingslash":
+			s, _ := v.(string)
+			p, exists := slashPolicies[strings.ToLower(s)]
+
+			if !exists {
+				return nil, fmt.Errorf("%s: expected lenient, strict or redirect", k)
+			}
+
+			r.options["SlashPolicy"] = p
+
+			continue
+
+		case "middleware":
+			if r.middleware, ok = stringList(v); !ok {
+				return nil, fmt.Errorf("%s: expected list of middleware names", k)
+			}
+
+			continue
+		}
+
+		if h, exists := hooks[strings.ToLower(k)]; exists {
+			s, ok := v.(string)
+
+			if !ok || s == "" {
+				return nil, fmt.Errorf("%s: expected handler name", k)
+			}
+
+			r.hooks[h] = s
+
+			continue
+		}
+
 		p, ok := v.(map[interface{}]interface{})
 
 		if !ok {
@@ -267,7 +756,7 @@ func loadRoutes() (*routes, error) {
 		}
 
 		switch u := strings.ToUpper(k); u {
-		case "PARAMS", "GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HELP":
+		case "PARAMS", "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HELP":
 			for a, b := range p {
 				t, ok := a.(string)
 
@@ -287,7 +776,7 @@ func loadRoutes() (*routes, error) {
 					continue
 				}
 
-				l = append(l, []string{u, t, f})
+				l = append(l, []string{u, t, f, k + " " + t})
 			}
 
 		default:
@@ -296,7 +785,23 @@ func loadRoutes() (*routes, error) {
 
 				if !ok {
 					break
-				} else if t != "GET" && t != "POST" && t != "PUT" && t != "PATCH" && t != "DELETE" && t != "OPTIONS" && t != "HELP" {
+				} else if strings.ToLower(t) == "name" {
+					s, ok := b.(string)
+
+					if !ok || s == "" {
+						return nil, fmt.Errorf("%s: expected route name", k)
+					}
+
+					n[k] = s
+
+					continue
+				} else if strings.ToLower(t) == "middleware" {
+					if mw[k], ok = stringList(b); !ok {
+						return nil, fmt.Errorf("%s: expected list of middleware names", k)
+					}
+
+					continue
+				} else if t != "GET" && t != "HEAD" && t != "POST" && t != "PUT" && t != "PATCH" && t != "DELETE" && t != "OPTIONS" && t != "HELP" {
 					continue
 				}
 
@@ -306,16 +811,146 @@ func loadRoutes() (*routes, error) {
 					continue
 				}
 
-				l = append(l, []string{t, k, f})
+				l = append(l, []string{t, k, f, k + " " + t})
 			}
 		}
 	}
 
+	// Package references
+	refs := r.middleware[:len(r.middleware):len(r.middleware)]
+
+	for _, k := range keys(r.params) {
+		refs = append(refs, r.params[k])
+	}
+
+	for _, k := range keys(r.hooks) {
+		refs = append(refs, r.hooks[k])
+	}
+
+	for _, v := range mw {
+		refs = append(refs, v...)
+	}
+
+	for _, c := range l {
+		refs = append(refs, c[2])
+	}
+
+	for _, v := range refs {
+		if err := r.ref(v); err != nil {
+			return nil, err
+		}
+	}
+
+	// Add routes in definition order
+	lines := lineNumbers(b)
+
+	sort.Slice(l, func(i, j int) bool {
+		if a, b := lines[l[i][3]], lines[l[j][3]]; a != b {
+			return a < b
+		}
+
+		return l[i][3] < l[j][3]
+	})
+
 	for _, c := range l {
-		if err = r.add(c[0], c[1], c[2]); err != nil {
+		if err := r.add(c[0], c[1], r.wrap(c[2], mw[c[1]]), n[c[1]], lines[c[3]]); err != nil {
 			return nil, err
 		}
 	}
 
 	return r, nil
 }
+
+// lineNumbers returns the routes.yaml line numbers of the route
+// definitions, keyed by their top level and nested keys, e.g.
+// "GET blog" or "blog GET", so routes defined in both the method
+// and path formats have their own line numbers.
+func lineNumbers(b []byte) map[string]int {
+	var (
+		n   = map[string]int{}
+		top string
+	)
+
+	for i, l := range strings.Split(string(b), "\n") {
+		j := strings.IndexByte(l, ':')
+
+		if j == -1 || strings.HasPrefix(strings.TrimSpace(l), "#") {
+			continue
+		}
+
+		k := strings.Trim(strings.TrimSpace(l[:j]), `"'`)
+
+		// Top level key; method or path
+		if l[0] != ' ' && l[0] != '\t' {
+			top = k
+
+			continue
+		}
+
+		n[top+" "+k] = i + 1
+	}
+
+	return n
+}
+
+// wrap returns the handler expression wrapped by the given route
+// middleware and the router middleware, the first middleware
+// being the outermost.
+func (r *routes) wrap(h string, mw []string) string {
+	h = handler(h)
+
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = middleware(mw[i]) + "(" + h + ")"
+	}
+
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = middleware(r.middleware[i]) + "(" + h + ")"
+	}
+
+	return h
+}
+
+// handler returns the handler expression for the routes.yaml handler,
+// adapting standard library handlers marked with "std:" (http.Handler)
+// or "stdfunc:" (func(http.ResponseWriter, *http.Request)).
+func handler(s string) string {
+	switch {
+	case strings.HasPrefix(s, "std:"):
+		return "router.Std(" + strings.TrimSpace(s[4:]) + ")"
+
+	case strings.HasPrefix(s, "stdfunc:"):
+		return "router.StdFunc(" + strings.TrimSpace(s[8:]) + ")"
+	}
+
+	return s
+}
+
+// middleware returns the middleware expression for the routes.yaml
+// middleware, adapting standard library middleware marked with "std:"
+// (func(http.Handler) http.Handler).
+func middleware(s string) string {
+	if strings.HasPrefix(s, "std:") {
+		return "router.StdMiddleware(" + strings.TrimSpace(s[4:]) + ")"
+	}
+
+	return s
+}
+
+// stringList returns the YAML list of non-empty strings.
+func stringList(v interface{}) ([]string, bool) {
+	l, ok := v.([]interface{})
+
+	if !ok {
+		return nil, false
+	}
+
+	s := make([]string, len(l))
+
+	for i, c := range l {
+		if s[i], ok = c.(string); !ok || s[i] == "" {
+			return nil, false
+		}
+	}
+
+	return s, true
+}